@@ -0,0 +1,357 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+)
+
+/*
+Block archives are a portable format for moving blocks between nodes
+without p2p, eg. to seed a new node from a snapshot.
+
+An archive is laid out as:
+ - Magic:    8 bytes, "TMBLOCKS"
+ - Version:  uint16, big endian
+ - Flags:    1 byte, archiveFlagGzip if the rest of the archive is gzipped
+ - Records:  the ArchiveHeader, then one ArchiveRecord per block, ascending by height
+ - End:      a record of length 0
+
+Each record is framed as a uint32 big endian length, the go-wire encoded
+record, and a uint32 big endian CRC-32 (Castagnoli) of the encoded record.
+An archive without the end record was truncated, eg. by an interrupted export.
+*/
+
+const (
+	// ArchiveVersion is the version of the archive format written by ArchiveWriter.
+	ArchiveVersion = uint16(1)
+
+	archiveMagic         = "TMBLOCKS"
+	archiveFlagGzip      = byte(0x01)
+	maxArchiveRecordSize = types.MaxBlockSize * 2 // parts, proofs and commits on top of the block
+)
+
+var (
+	ErrArchiveBadMagic      = errors.New("Not a block archive")
+	ErrArchiveBadVersion    = errors.New("Unsupported block archive version")
+	ErrArchiveBadChecksum   = errors.New("Block archive record checksum mismatch")
+	ErrArchiveRecordTooBig  = errors.New("Block archive record too big")
+	ErrArchiveTruncated     = errors.New("Block archive is truncated")
+	ErrArchiveChainMismatch = errors.New("Block archive is for another chain")
+
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ArchiveHeader describes the blocks in an archive.
+type ArchiveHeader struct {
+	ChainID    string
+	FromHeight int
+	ToHeight   int
+}
+
+// ArchiveRecord holds everything needed to verify, store and replay one block.
+// The block is stored as its parts so the PartSetHeader in the BlockMeta
+// can be checked without knowing the part size it was made with.
+type ArchiveRecord struct {
+	BlockMeta  *types.BlockMeta
+	Parts      []*types.Part
+	SeenCommit *types.Commit
+}
+
+//-----------------------------------------------------------------------------
+
+// ArchiveWriter writes a block archive.
+// Close must be called to write the end of the archive.
+type ArchiveWriter struct {
+	bw *bufio.Writer
+	gz *gzip.Writer
+	w  io.Writer // bw, or gz on top of bw
+}
+
+// NewArchiveWriter writes the archive preamble and header to w.
+func NewArchiveWriter(w io.Writer, header ArchiveHeader, compress bool) (*ArchiveWriter, error) {
+	aw := &ArchiveWriter{bw: bufio.NewWriter(w)}
+	aw.w = aw.bw
+
+	var flags byte
+	if compress {
+		flags |= archiveFlagGzip
+	}
+	preamble := make([]byte, len(archiveMagic)+3)
+	copy(preamble, archiveMagic)
+	binary.BigEndian.PutUint16(preamble[len(archiveMagic):], ArchiveVersion)
+	preamble[len(archiveMagic)+2] = flags
+	if _, err := aw.bw.Write(preamble); err != nil {
+		return nil, err
+	}
+
+	if compress {
+		aw.gz = gzip.NewWriter(aw.bw)
+		aw.w = aw.gz
+	}
+	if err := aw.writeRecord(wire.BinaryBytes(header)); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// WriteRecord appends a block to the archive.
+func (aw *ArchiveWriter) WriteRecord(rec *ArchiveRecord) error {
+	return aw.writeRecord(wire.BinaryBytes(rec))
+}
+
+// Close writes the end of the archive and flushes it.
+// It does not close the underlying writer.
+func (aw *ArchiveWriter) Close() error {
+	if err := aw.writeUint32(0); err != nil {
+		return err
+	}
+	if aw.gz != nil {
+		if err := aw.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return aw.bw.Flush()
+}
+
+func (aw *ArchiveWriter) writeRecord(bz []byte) error {
+	if len(bz) > maxArchiveRecordSize {
+		return ErrArchiveRecordTooBig
+	}
+	if err := aw.writeUint32(uint32(len(bz))); err != nil {
+		return err
+	}
+	if _, err := aw.w.Write(bz); err != nil {
+		return err
+	}
+	return aw.writeUint32(crc32.Checksum(bz, crc32c))
+}
+
+func (aw *ArchiveWriter) writeUint32(i uint32) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], i)
+	_, err := aw.w.Write(buf[:])
+	return err
+}
+
+//-----------------------------------------------------------------------------
+
+// ArchiveReader reads a block archive written by ArchiveWriter.
+type ArchiveReader struct {
+	r      io.Reader
+	header ArchiveHeader
+}
+
+// NewArchiveReader checks the archive preamble and reads the header from r.
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	br := bufio.NewReader(r)
+	preamble := make([]byte, len(archiveMagic)+3)
+	if _, err := io.ReadFull(br, preamble); err != nil {
+		return nil, ErrArchiveBadMagic
+	}
+	if string(preamble[:len(archiveMagic)]) != archiveMagic {
+		return nil, ErrArchiveBadMagic
+	}
+	if binary.BigEndian.Uint16(preamble[len(archiveMagic):]) != ArchiveVersion {
+		return nil, ErrArchiveBadVersion
+	}
+
+	ar := &ArchiveReader{r: br}
+	if preamble[len(archiveMagic)+2]&archiveFlagGzip != 0 {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		ar.r = gz
+	}
+
+	bz, err := ar.readRecord()
+	if err == io.EOF {
+		return nil, ErrArchiveTruncated
+	} else if err != nil {
+		return nil, err
+	}
+	var n int
+	wire.ReadBinary(&ar.header, bytes.NewReader(bz), maxArchiveRecordSize, &n, &err)
+	if err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// Header returns the archive header.
+func (ar *ArchiveReader) Header() ArchiveHeader {
+	return ar.header
+}
+
+// ReadRecord returns the next block in the archive,
+// or io.EOF at the end of the archive.
+func (ar *ArchiveReader) ReadRecord() (*ArchiveRecord, error) {
+	bz, err := ar.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	rec := wire.ReadBinary(&ArchiveRecord{}, bytes.NewReader(bz), maxArchiveRecordSize, &n, &err).(*ArchiveRecord)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (ar *ArchiveReader) readRecord() ([]byte, error) {
+	length, err := ar.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return nil, io.EOF
+	}
+	if length > maxArchiveRecordSize {
+		return nil, ErrArchiveRecordTooBig
+	}
+	bz := make([]byte, length)
+	if _, err := io.ReadFull(ar.r, bz); err != nil {
+		return nil, ErrArchiveTruncated
+	}
+	checksum, err := ar.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if checksum != crc32.Checksum(bz, crc32c) {
+		return nil, ErrArchiveBadChecksum
+	}
+	return bz, nil
+}
+
+func (ar *ArchiveReader) readUint32() (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(ar.r, buf[:]); err != nil {
+		// we never expect EOF here, since an archive ends with an empty record
+		return 0, ErrArchiveTruncated
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// ExportBlocks writes the blocks from height `from` to `to` (inclusive),
+// along with their metas and seen commits, to the archive.
+func ExportBlocks(store types.BlockStoreRPC, aw *ArchiveWriter, from, to int) error {
+	for height := from; height <= to; height++ {
+		meta := store.LoadBlockMeta(height)
+		if meta == nil {
+			return sm.ErrUnknownBlock{height}
+		}
+		parts := make([]*types.Part, meta.BlockID.PartsHeader.Total)
+		for i := range parts {
+			parts[i] = store.LoadBlockPart(height, i)
+		}
+		rec := &ArchiveRecord{
+			BlockMeta:  meta,
+			Parts:      parts,
+			SeenCommit: store.LoadSeenCommit(height),
+		}
+		if err := aw.WriteRecord(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportBlocks verifies the blocks in the archive, saves them to the store
+// and applies them to the state and the app, like fast sync does.
+// The state, store and app must be synced up (ie. after the Handshake).
+// Blocks the store already has are skipped, if they match the archive's,
+// so an interrupted import can be resumed by running it again.
+// It returns the number of blocks imported.
+func ImportBlocks(ar *ArchiveReader, state *sm.State, store *BlockStore, proxyAppConn proxy.AppConnConsensus, logger log.Logger) (int, error) {
+	if ar.Header().ChainID != state.ChainID {
+		return 0, ErrArchiveChainMismatch
+	}
+	if state.LastBlockHeight != store.Height() {
+		return 0, errors.New(cmn.Fmt("State (%d) and store (%d) heights don't match", state.LastBlockHeight, store.Height()))
+	}
+
+	nBlocks := 0
+	for {
+		rec, err := ar.ReadRecord()
+		if err == io.EOF {
+			return nBlocks, nil
+		} else if err != nil {
+			return nBlocks, err
+		}
+
+		height := rec.BlockMeta.Header.Height
+		if height <= store.Height() {
+			meta := store.LoadBlockMeta(height)
+			if meta == nil || !meta.BlockID.Equals(rec.BlockMeta.BlockID) {
+				return nBlocks, errors.New(cmn.Fmt("Block %d in archive does not match the one in the store", height))
+			}
+			continue
+		} else if height != store.Height()+1 {
+			return nBlocks, errors.New(cmn.Fmt("Block archive skips from height %d to %d", store.Height(), height))
+		}
+
+		block, blockParts, err := verifyArchiveRecord(state, rec)
+		if err != nil {
+			return nBlocks, errors.New(cmn.Fmt("Invalid block %d in archive: %v", height, err))
+		}
+
+		// same order as finalizeCommit: save the block, then apply it
+		store.SaveBlock(block, blockParts, rec.SeenCommit)
+		err = state.ApplyBlock(nil, proxyAppConn, block, blockParts.Header(), types.MockMempool{})
+		if err != nil {
+			return nBlocks, err
+		}
+
+		nBlocks++
+		logger.Info("Imported block", "height", height, "hash", block.Hash())
+	}
+}
+
+// verifyArchiveRecord rebuilds the block from its parts and checks it
+// against its meta, the state and the +2/3 precommits for it.
+func verifyArchiveRecord(state *sm.State, rec *ArchiveRecord) (*types.Block, *types.PartSet, error) {
+	blockID := rec.BlockMeta.BlockID
+	blockParts := types.NewPartSetFromHeader(blockID.PartsHeader)
+	for _, part := range rec.Parts {
+		if _, err := blockParts.AddPart(part, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !blockParts.IsComplete() {
+		return nil, nil, errors.New("Missing block parts")
+	}
+
+	var n int
+	var err error
+	block := wire.ReadBinary(&types.Block{}, blockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !block.HashesTo(blockID.Hash) {
+		return nil, nil, errors.New("Block does not hash to its BlockMeta")
+	}
+
+	// LastCommit against the last validators, and the header against the state
+	if err := state.ValidateBlock(block); err != nil {
+		return nil, nil, err
+	}
+	// the block itself against the current validators
+	if err := state.Validators.VerifyCommit(state.ChainID, blockID, block.Height, rec.SeenCommit); err != nil {
+		return nil, nil, err
+	}
+	return block, blockParts, nil
+}
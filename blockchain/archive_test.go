@@ -0,0 +1,201 @@
+package blockchain
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/abci/example/dummy"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+	"github.com/tendermint/tmlibs/log"
+)
+
+const archiveTestChainID = "archive_test"
+
+// makeArchiveTestStore saves nBlocks blocks of a few small parts each.
+func makeArchiveTestStore(nBlocks int) *BlockStore {
+	store := NewBlockStore(dbm.NewMemDB())
+	saveTestBlocks(store, archiveTestChainID, nBlocks)
+	return store
+}
+
+func writeTestArchive(t *testing.T, store *BlockStore, compress bool) []byte {
+	buf := new(bytes.Buffer)
+	header := ArchiveHeader{archiveTestChainID, 1, store.Height()}
+	aw, err := NewArchiveWriter(buf, header, compress)
+	require.Nil(t, err)
+	require.Nil(t, ExportBlocks(store, aw, 1, store.Height()))
+	require.Nil(t, aw.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	store := makeArchiveTestStore(5)
+
+	for _, compress := range []bool{false, true} {
+		assert, require := assert.New(t), require.New(t)
+
+		bz := writeTestArchive(t, store, compress)
+		ar, err := NewArchiveReader(bytes.NewReader(bz))
+		require.Nil(err)
+		assert.Equal(ArchiveHeader{archiveTestChainID, 1, 5}, ar.Header())
+
+		for height := 1; height <= 5; height++ {
+			rec, err := ar.ReadRecord()
+			require.Nil(err, "height %d", height)
+			assert.Equal(store.LoadBlockMeta(height), rec.BlockMeta)
+			assert.Equal(store.LoadSeenCommit(height), rec.SeenCommit)
+
+			blockParts := types.NewPartSetFromHeader(rec.BlockMeta.BlockID.PartsHeader)
+			for _, part := range rec.Parts {
+				_, err := blockParts.AddPart(part, true)
+				require.Nil(err)
+			}
+			assert.True(blockParts.IsComplete())
+		}
+		_, err = ar.ReadRecord()
+		assert.Equal(io.EOF, err)
+	}
+}
+
+func TestArchiveCorrupt(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := makeArchiveTestStore(2)
+	bz := writeTestArchive(t, store, false)
+
+	// not an archive
+	_, err := NewArchiveReader(bytes.NewReader([]byte("hello world")))
+	assert.Equal(ErrArchiveBadMagic, err)
+
+	// flip a byte in the last block
+	corrupt := make([]byte, len(bz))
+	copy(corrupt, bz)
+	corrupt[len(corrupt)-20] ^= 0xFF
+	ar, err := NewArchiveReader(bytes.NewReader(corrupt))
+	require.Nil(err)
+	_, err = ar.ReadRecord()
+	require.Nil(err)
+	_, err = ar.ReadRecord()
+	assert.Equal(ErrArchiveBadChecksum, err)
+
+	// cut off the end of the archive
+	ar, err = NewArchiveReader(bytes.NewReader(bz[:len(bz)-4]))
+	require.Nil(err)
+	_, err = ar.ReadRecord()
+	require.Nil(err)
+	_, err = ar.ReadRecord()
+	require.Nil(err)
+	_, err = ar.ReadRecord()
+	assert.Equal(ErrArchiveTruncated, err)
+}
+
+// importTestChain is a chain with one validator, whose blocks are signed
+// and applied to a dummy app, so they can be imported.
+type importTestChain struct {
+	privKey crypto.PrivKey
+	genDoc  *types.GenesisDoc
+}
+
+func newImportTestChain() *importTestChain {
+	privKey := crypto.GenPrivKeyEd25519().Wrap()
+	return &importTestChain{
+		privKey: privKey,
+		genDoc: &types.GenesisDoc{
+			ChainID:    archiveTestChainID,
+			Validators: []types.GenesisValidator{{privKey.PubKey(), 10, "test"}},
+		},
+	}
+}
+
+// node returns the genesis state, an empty store, and a fresh app.
+func (c *importTestChain) node(t *testing.T) (*sm.State, *BlockStore, proxy.AppConnConsensus) {
+	state := sm.MakeGenesisState(dbm.NewMemDB(), c.genDoc)
+	state.SetLogger(log.TestingLogger())
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(dummy.NewDummyApplication()), nil)
+	_, err := proxyApp.Start()
+	require.Nil(t, err)
+	return state, NewBlockStore(dbm.NewMemDB()), proxyApp.Consensus()
+}
+
+// commitBlocks makes, saves and applies nBlocks blocks with a tx each.
+func (c *importTestChain) commitBlocks(t *testing.T, state *sm.State, store *BlockStore,
+	proxyAppConn proxy.AppConnConsensus, nBlocks int, txPrefix string) {
+	lastCommit := &types.Commit{}
+	for height := 1; height <= nBlocks; height++ {
+		txs := []types.Tx{types.Tx(txPrefix + string(rune('0'+height)))}
+		block, blockParts := types.MakeBlock(height, archiveTestChainID, txs, lastCommit,
+			state.LastBlockID, state.Validators.Hash(), state.AppHash, 1024)
+		blockID := types.BlockID{block.Hash(), blockParts.Header()}
+		vote := &types.Vote{
+			ValidatorAddress: c.privKey.PubKey().Address(),
+			ValidatorIndex:   0,
+			Height:           height,
+			Type:             types.VoteTypePrecommit,
+			BlockID:          blockID,
+		}
+		vote.Signature = c.privKey.Sign(types.SignBytes(archiveTestChainID, vote))
+		commit := &types.Commit{BlockID: blockID, Precommits: []*types.Vote{vote}}
+
+		store.SaveBlock(block, blockParts, commit)
+		require.Nil(t, state.ApplyBlock(nil, proxyAppConn, block, blockParts.Header(), types.MockMempool{}))
+		lastCommit = commit
+	}
+}
+
+func TestImportBlocks(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	chain := newImportTestChain()
+	state, store, proxyAppConn := chain.node(t)
+	chain.commitBlocks(t, state, store, proxyAppConn, 5, "tx")
+	bz := writeTestArchive(t, store, true)
+
+	// a new node imports every block, and ends up where the chain is
+	newState, newStore, newProxyAppConn := chain.node(t)
+	ar, err := NewArchiveReader(bytes.NewReader(bz))
+	require.Nil(err)
+	n, err := ImportBlocks(ar, newState, newStore, newProxyAppConn, log.TestingLogger())
+	require.Nil(err)
+	assert.Equal(5, n)
+	assert.Equal(5, newStore.Height())
+	assert.Equal(5, newState.LastBlockHeight)
+	assert.Equal(state.AppHash, newState.AppHash)
+	for height := 1; height <= 5; height++ {
+		assert.Equal(store.LoadBlockMeta(height).BlockID, newStore.LoadBlockMeta(height).BlockID)
+	}
+
+	// importing again skips the blocks it has
+	ar, err = NewArchiveReader(bytes.NewReader(bz))
+	require.Nil(err)
+	n, err = ImportBlocks(ar, newState, newStore, newProxyAppConn, log.TestingLogger())
+	assert.Nil(err)
+	assert.Zero(n)
+
+	// a node with other blocks at the same heights doesn't import them
+	otherState, otherStore, otherProxyAppConn := chain.node(t)
+	chain.commitBlocks(t, otherState, otherStore, otherProxyAppConn, 2, "other")
+	ar, err = NewArchiveReader(bytes.NewReader(bz))
+	require.Nil(err)
+	n, err = ImportBlocks(ar, otherState, otherStore, otherProxyAppConn, log.TestingLogger())
+	assert.NotNil(err)
+	assert.Zero(n)
+	assert.Equal(2, otherStore.Height())
+
+	// neither does a node whose state and store are out of sync
+	badState, badStore, badProxyAppConn := chain.node(t)
+	chain.commitBlocks(t, badState, badStore, badProxyAppConn, 1, "tx")
+	badState.LastBlockHeight = 0
+	ar, err = NewArchiveReader(bytes.NewReader(bz))
+	require.Nil(err)
+	assert.NotPanics(func() {
+		_, err = ImportBlocks(ar, badState, badStore, badProxyAppConn, log.TestingLogger())
+	})
+	assert.NotNil(err)
+}
@@ -186,7 +186,8 @@ func (pool *BlockPool) PopRequest() {
 
 // Invalidates the block at pool.height,
 // Remove the peer and redo request from others.
-func (pool *BlockPool) RedoRequest(height int) {
+// Returns the ID of the peer that sent the block.
+func (pool *BlockPool) RedoRequest(height int) string {
 	pool.mtx.Lock()
 	request := pool.requesters[height]
 	pool.mtx.Unlock()
@@ -195,27 +196,29 @@ func (pool *BlockPool) RedoRequest(height int) {
 		PanicSanity("Expected block to be non-nil")
 	}
 	// RemovePeer will redo all requesters associated with this peer.
-	// TODO: record this malfeasance
 	pool.RemovePeer(request.peerID)
+	return request.peerID
 }
 
+// AddBlock returns false if we didn't request the block from the peer.
 // TODO: ensure that blocks come in order for each peer.
-func (pool *BlockPool) AddBlock(peerID string, block *types.Block, blockSize int) {
+func (pool *BlockPool) AddBlock(peerID string, block *types.Block, blockSize int) bool {
 	pool.mtx.Lock()
 	defer pool.mtx.Unlock()
 
 	requester := pool.requesters[block.Height]
 	if requester == nil {
-		return
+		return false
 	}
 
-	if requester.setBlock(block, peerID) {
-		pool.numPending--
-		peer := pool.peers[peerID]
-		peer.decrPending(blockSize)
-	} else {
+	if !requester.setBlock(block, peerID) {
 		// Bad peer?
+		return false
 	}
+	pool.numPending--
+	peer := pool.peers[peerID]
+	peer.decrPending(blockSize)
+	return true
 }
 
 // Sets the peer's alleged blockchain height.
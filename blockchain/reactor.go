@@ -148,7 +148,10 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 		}
 	case *bcBlockResponseMessage:
 		// Got a block.
-		bcR.pool.AddBlock(src.Key, msg.Block, len(msgBytes))
+		// only requested blocks count, so peers can't earn trust by sending unrequested ones
+		if bcR.pool.AddBlock(src.Key, msg.Block, len(msgBytes)) {
+			bcR.Switch.ReportPeerEvent(src, p2p.PeerEventUsefulData)
+		}
 	case *bcStatusRequestMessage:
 		// Send peer our state.
 		queued := src.TrySend(BlockchainChannel, struct{ BlockchainMessage }{&bcStatusResponseMessage{bcR.store.Height()}})
@@ -192,6 +195,7 @@ FOR_LOOP:
 			peer := bcR.Switch.Peers().Get(peerID)
 			if peer != nil {
 				bcR.Switch.StopPeerForError(peer, errors.New("BlockchainReactor Timeout"))
+				bcR.Switch.ReportPeerEvent(peer, p2p.PeerEventTimeout)
 			}
 		case <-statusUpdateTicker.C:
 			// ask for status updates
@@ -231,7 +235,10 @@ FOR_LOOP:
 					bcR.state.ChainID, types.BlockID{first.Hash(), firstPartsHeader}, first.Height, second.LastCommit)
 				if err != nil {
 					bcR.Logger.Info("error in validation", "err", err)
-					bcR.pool.RedoRequest(first.Height)
+					peerID := bcR.pool.RedoRequest(first.Height)
+					if peer := bcR.Switch.Peers().Get(peerID); peer != nil {
+						bcR.Switch.ReportPeerEvent(peer, p2p.PeerEventInvalidBlock)
+					}
 					break SYNC_LOOP
 				} else {
 					bcR.pool.PopRequest()
@@ -10,16 +10,21 @@ import (
 	. "github.com/tendermint/tmlibs/common"
 	dbm "github.com/tendermint/tmlibs/db"
 	"github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/fail"
 	"github.com/tendermint/tendermint/types"
 )
 
+// Crash point for testing recovery, see package fail.
+var failSaveBlockBeforeWrite = fail.Point("blockchain/SaveBlock/beforeWrite")
+
 /*
 Simple low level store for blocks.
 
-There are three types of information stored:
+There are four types of information stored:
  - BlockMeta:   Meta information about each block
  - Block part:  Parts of each block, aggregated w/ PartSet
  - Commit:      The commit part of each block, for gossiping precommit votes
+ - Block hash:  The height of each block by its hash, for lookups by hash
 
 Currently the precommit signatures are duplicated in the Block parts as
 well as the Commit.  In the future this may change, perhaps by moving
@@ -36,10 +41,12 @@ type BlockStore struct {
 
 func NewBlockStore(db dbm.DB) *BlockStore {
 	bsjson := LoadBlockStoreStateJSON(db)
-	return &BlockStore{
+	bs := &BlockStore{
 		height: bsjson.Height,
 		db:     db,
 	}
+	bs.migrateBlockHashIndex(bsjson)
+	return bs
 }
 
 // Height() returns the last known contiguous block height.
@@ -108,6 +115,30 @@ func (bs *BlockStore) LoadBlockMeta(height int) *types.BlockMeta {
 	return blockMeta
 }
 
+// LoadBlockHeightByHash returns the height of the block with the given hash,
+// or 0 if the store does not have it.
+func (bs *BlockStore) LoadBlockHeightByHash(hash []byte) int {
+	var n int
+	var err error
+	r := bs.GetReader(calcBlockHashKey(hash))
+	if r == nil {
+		return 0
+	}
+	height := wire.ReadVarint(r, &n, &err)
+	if err != nil {
+		PanicCrisis(Fmt("Error reading block height: %v", err))
+	}
+	return height
+}
+
+func (bs *BlockStore) LoadBlockByHash(hash []byte) *types.Block {
+	height := bs.LoadBlockHeightByHash(hash)
+	if height == 0 {
+		return nil
+	}
+	return bs.LoadBlock(height)
+}
+
 // The +2/3 and other Precommit-votes for block at `height`.
 // This Commit comes from block.LastCommit for `height+1`.
 func (bs *BlockStore) LoadBlockCommit(height int) *types.Commit {
@@ -144,6 +175,8 @@ func (bs *BlockStore) LoadSeenCommit(height int) *types.Commit {
 //             If all the nodes restart after committing a block,
 //             we need this to reload the precommits to catch-up nodes to the
 //             most recent height.  Otherwise they'd stall at H-1.
+// The block is written in a single batch, so after a crash
+// either all of it or none of it is in the store.
 func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit) {
 	height := block.Height
 	if height != bs.Height()+1 {
@@ -153,27 +186,37 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 		PanicSanity(Fmt("BlockStore can only save complete block part sets"))
 	}
 
+	batch := bs.db.NewBatch()
+
 	// Save block meta
 	blockMeta := types.NewBlockMeta(block, blockParts)
 	metaBytes := wire.BinaryBytes(blockMeta)
-	bs.db.Set(calcBlockMetaKey(height), metaBytes)
+	batch.Set(calcBlockMetaKey(height), metaBytes)
 
 	// Save block parts
 	for i := 0; i < blockParts.Total(); i++ {
-		bs.saveBlockPart(height, i, blockParts.GetPart(i))
+		partBytes := wire.BinaryBytes(blockParts.GetPart(i))
+		batch.Set(calcBlockPartKey(height, i), partBytes)
 	}
 
 	// Save block commit (duplicate and separate from the Block)
 	blockCommitBytes := wire.BinaryBytes(block.LastCommit)
-	bs.db.Set(calcBlockCommitKey(height-1), blockCommitBytes)
+	batch.Set(calcBlockCommitKey(height-1), blockCommitBytes)
 
 	// Save seen commit (seen +2/3 precommits for block)
 	// NOTE: we can delete this at a later height
 	seenCommitBytes := wire.BinaryBytes(seenCommit)
-	bs.db.Set(calcSeenCommitKey(height), seenCommitBytes)
+	batch.Set(calcSeenCommitKey(height), seenCommitBytes)
+
+	// Save block hash index
+	batch.Set(calcBlockHashKey(blockMeta.BlockID.Hash), wire.BinaryBytes(height))
 
 	// Save new BlockStoreStateJSON descriptor
-	BlockStoreStateJSON{Height: height}.Save(bs.db)
+	batch.Set(blockStoreKey, BlockStoreStateJSON{Height: height, HashIndexHeight: height}.Bytes())
+
+	fail.Fail(failSaveBlockBeforeWrite)
+
+	batch.Write()
 
 	// Done!
 	bs.mtx.Lock()
@@ -184,14 +227,6 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 	bs.db.SetSync(nil, nil)
 }
 
-func (bs *BlockStore) saveBlockPart(height int, index int, part *types.Part) {
-	if height != bs.Height()+1 {
-		PanicSanity(Fmt("BlockStore can only save contiguous blocks. Wanted %v, got %v", bs.Height()+1, height))
-	}
-	partBytes := wire.BinaryBytes(part)
-	bs.db.Set(calcBlockPartKey(height, index), partBytes)
-}
-
 //-----------------------------------------------------------------------------
 
 func calcBlockMetaKey(height int) []byte {
@@ -210,20 +245,56 @@ func calcSeenCommitKey(height int) []byte {
 	return []byte(fmt.Sprintf("SC:%v", height))
 }
 
+func calcBlockHashKey(hash []byte) []byte {
+	return []byte(fmt.Sprintf("BH:%X", hash))
+}
+
+//-----------------------------------------------------------------------------
+
+// number of blocks indexed per batch by migrateBlockHashIndex
+const blockHashIndexBatchSize = 1000
+
+// migrateBlockHashIndex backfills the block hash index for blocks saved
+// before SaveBlock maintained it. Progress is saved with each batch,
+// so an interrupted migration picks up where it left off.
+// Once it is done, SaveBlock keeps HashIndexHeight equal to Height.
+func (bs *BlockStore) migrateBlockHashIndex(bsjson BlockStoreStateJSON) {
+	for bsjson.HashIndexHeight < bsjson.Height {
+		batch := bs.db.NewBatch()
+		to := MinInt(bsjson.HashIndexHeight+blockHashIndexBatchSize, bsjson.Height)
+		for height := bsjson.HashIndexHeight + 1; height <= to; height++ {
+			blockMeta := bs.LoadBlockMeta(height)
+			if blockMeta == nil {
+				PanicCrisis(Fmt("Missing block meta at height %v", height))
+			}
+			batch.Set(calcBlockHashKey(blockMeta.BlockID.Hash), wire.BinaryBytes(height))
+		}
+		bsjson.HashIndexHeight = to
+		batch.Set(blockStoreKey, bsjson.Bytes())
+		batch.Write()
+	}
+	bs.db.SetSync(nil, nil)
+}
+
 //-----------------------------------------------------------------------------
 
 var blockStoreKey = []byte("blockStore")
 
 type BlockStoreStateJSON struct {
-	Height int
+	Height          int
+	HashIndexHeight int // blocks up to here are in the block hash index
 }
 
 func (bsj BlockStoreStateJSON) Save(db dbm.DB) {
+	db.SetSync(blockStoreKey, bsj.Bytes())
+}
+
+func (bsj BlockStoreStateJSON) Bytes() []byte {
 	bytes, err := json.Marshal(bsj)
 	if err != nil {
 		PanicSanity(Fmt("Could not marshal state bytes: %v", err))
 	}
-	db.SetSync(blockStoreKey, bytes)
+	return bytes
 }
 
 func LoadBlockStoreStateJSON(db dbm.DB) BlockStoreStateJSON {
@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+// saveTestBlocks saves nBlocks blocks of a few small parts each to an empty store.
+func saveTestBlocks(store *BlockStore, chainID string, nBlocks int) {
+	lastBlockID := types.BlockID{}
+	for height := 1; height <= nBlocks; height++ {
+		txs := []types.Tx{types.Tx("tx1"), types.Tx("tx2"), types.Tx(bytes.Repeat([]byte{byte(height)}, 300))}
+		block, parts := types.MakeBlock(height, chainID, txs, new(types.Commit),
+			lastBlockID, nil, nil, 128)
+		store.SaveBlock(block, parts, new(types.Commit))
+		lastBlockID = types.BlockID{block.Hash(), parts.Header()}
+	}
+}
+
+func TestBlockHashIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	db := dbm.NewMemDB()
+	store := NewBlockStore(db)
+	saveTestBlocks(store, "store_test", 5)
+
+	checkIndex := func(store *BlockStore) {
+		for height := 1; height <= 5; height++ {
+			hash := store.LoadBlockMeta(height).BlockID.Hash
+			assert.Equal(height, store.LoadBlockHeightByHash(hash))
+			assert.Equal(store.LoadBlock(height), store.LoadBlockByHash(hash))
+		}
+		assert.Equal(0, store.LoadBlockHeightByHash([]byte("not a block hash")))
+		assert.Nil(store.LoadBlockByHash([]byte("not a block hash")))
+	}
+	checkIndex(store)
+
+	// make it look like a store saved before the index existed
+	for height := 1; height <= 5; height++ {
+		db.Delete(calcBlockHashKey(store.LoadBlockMeta(height).BlockID.Hash))
+	}
+	BlockStoreStateJSON{Height: 5}.Save(db)
+	assert.Equal(0, store.LoadBlockHeightByHash(store.LoadBlockMeta(1).BlockID.Hash))
+
+	// reopening the store backfills the index
+	store = NewBlockStore(db)
+	assert.Equal(5, LoadBlockStoreStateJSON(db).HashIndexHeight)
+	checkIndex(store)
+}
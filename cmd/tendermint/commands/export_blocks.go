@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	bc "github.com/tendermint/tendermint/blockchain"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+var exportBlocksCmd = &cobra.Command{
+	Use:     "export-blocks [file]",
+	Aliases: []string{"export_blocks"},
+	Short:   "Export blocks from the block store to an archive file (node must be stopped)",
+	RunE:    exportBlocks,
+}
+
+// flags
+var (
+	exportFrom     int
+	exportTo       int
+	exportCompress bool
+)
+
+func init() {
+	exportBlocksCmd.Flags().IntVar(&exportFrom, "from", 1, "First block height to export")
+	exportBlocksCmd.Flags().IntVar(&exportTo, "to", 0, "Last block height to export (0 means the latest)")
+	exportBlocksCmd.Flags().BoolVar(&exportCompress, "compress", false, "Gzip the archive")
+	RootCmd.AddCommand(exportBlocksCmd)
+}
+
+func exportBlocks(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: tendermint export-blocks [--from height] [--to height] file")
+	}
+
+	genDoc, err := types.GenesisDocFromFile(config.GenesisFile())
+	if err != nil {
+		return err
+	}
+	blockStore := bc.NewBlockStore(dbm.NewDB("blockstore", config.DBBackend, config.DBDir()))
+
+	to := exportTo
+	if to == 0 || to > blockStore.Height() {
+		to = blockStore.Height()
+	}
+	if exportFrom < 1 || exportFrom > to {
+		return errors.New(cmn.Fmt("Invalid range: from %d to %d (store height %d)", exportFrom, to, blockStore.Height()))
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := bc.ArchiveHeader{
+		ChainID:    genDoc.ChainID,
+		FromHeight: exportFrom,
+		ToHeight:   to,
+	}
+	aw, err := bc.NewArchiveWriter(f, header, exportCompress)
+	if err != nil {
+		return err
+	}
+	if err := bc.ExportBlocks(blockStore, aw, exportFrom, to); err != nil {
+		return err
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	logger.Info("Exported blocks", "from", exportFrom, "to", to, "file", args[0])
+	return nil
+}
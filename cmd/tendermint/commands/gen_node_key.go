@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tendermint/tendermint/p2p"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+var genNodeKeyCmd = &cobra.Command{
+	Use:   "gen_node_key",
+	Short: "Generate a node key for this node and print its ID",
+	Run:   genNodeKey,
+}
+
+func init() {
+	RootCmd.AddCommand(genNodeKeyCmd)
+}
+
+func genNodeKey(cmd *cobra.Command, args []string) {
+	nodeKeyFile := config.NodeKeyFile()
+	if cmn.FileExists(nodeKeyFile) {
+		cmn.Exit(cmn.Fmt("Node key at %v already exists", nodeKeyFile))
+	}
+	nodeKey, err := p2p.GenNodeKey(nodeKeyFile)
+	if err != nil {
+		cmn.Exit(err.Error())
+	}
+	fmt.Println(nodeKey.ID())
+}
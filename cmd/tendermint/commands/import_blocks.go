@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	bc "github.com/tendermint/tendermint/blockchain"
+	"github.com/tendermint/tendermint/consensus"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/state/txindex/kv"
+	cmn "github.com/tendermint/tmlibs/common"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+var importBlocksCmd = &cobra.Command{
+	Use:     "import-blocks [file]",
+	Aliases: []string{"import_blocks"},
+	Short:   "Verify and execute the blocks in an archive file (node must be stopped)",
+	Long: `Verify and execute the blocks in an archive file written by export-blocks.
+Each block is checked against its commit and the validator set,
+saved to the block store, and executed by the ABCI app.
+Blocks already in the block store are skipped,
+so an interrupted import can be resumed by running it again.`,
+	RunE: importBlocks,
+}
+
+func init() {
+	importBlocksCmd.Flags().String("proxy_app", config.ProxyApp, "Proxy app address, or 'nilapp' or 'dummy' for local testing.")
+	importBlocksCmd.Flags().String("abci", config.ABCI, "Specify abci transport (socket | grpc)")
+	RootCmd.AddCommand(importBlocksCmd)
+}
+
+func importBlocks(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: tendermint import-blocks file")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ar, err := bc.NewArchiveReader(f)
+	if err != nil {
+		return err
+	}
+
+	blockStore := bc.NewBlockStore(dbm.NewDB("blockstore", config.DBBackend, config.DBDir()))
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := sm.GetState(stateDB, config.GenesisFile())
+	state.SetLogger(logger.With("module", "state"))
+
+	// sync the app with the store first,
+	// which also finishes off a block from an interrupted import
+	handshaker := consensus.NewHandshaker(state, blockStore)
+	handshaker.SetLogger(logger.With("module", "consensus"))
+	clientCreator := proxy.DefaultClientCreator(config.ProxyApp, config.ABCI, config.DBDir())
+	proxyApp := proxy.NewAppConns(clientCreator, handshaker)
+	proxyApp.SetLogger(logger.With("module", "proxy"))
+	if _, err := proxyApp.Start(); err != nil {
+		return errors.New(cmn.Fmt("Error starting proxy app connections: %v", err))
+	}
+	defer proxyApp.Stop()
+
+	// reload the state (it may have been updated by the handshake)
+	state = sm.LoadState(stateDB)
+	state.SetLogger(logger.With("module", "state"))
+	if config.TxIndex == "kv" {
+		state.TxIndexer = kv.NewTxIndex(dbm.NewDB("tx_index", config.DBBackend, config.DBDir()))
+	}
+
+	header := ar.Header()
+	logger.Info("Importing blocks", "from", header.FromHeight, "to", header.ToHeight, "storeHeight", blockStore.Height())
+	nBlocks, err := bc.ImportBlocks(ar, state, blockStore, proxyApp.Consensus(), logger.With("module", "blockchain"))
+	if err != nil {
+		return errors.New(cmn.Fmt("Imported %d blocks, then failed: %v", nBlocks, err))
+	}
+
+	logger.Info("Imported blocks", "count", nBlocks, "height", blockStore.Height())
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/types"
 	cmn "github.com/tendermint/tmlibs/common"
 )
@@ -44,4 +45,15 @@ func initFiles(cmd *cobra.Command, args []string) {
 	} else {
 		logger.Info("Already initialized", "priv_validator", config.PrivValidatorFile())
 	}
+
+	nodeKeyFile := config.NodeKeyFile()
+	if _, err := os.Stat(nodeKeyFile); os.IsNotExist(err) {
+		nodeKey, err := p2p.GenNodeKey(nodeKeyFile)
+		if err != nil {
+			cmn.Exit(cmn.Fmt("Failed to generate node key: %v", err))
+		}
+		logger.Info("Generated node key", "node_key", nodeKeyFile, "id", nodeKey.ID())
+	} else {
+		logger.Info("Found node key", "node_key", nodeKeyFile)
+	}
 }
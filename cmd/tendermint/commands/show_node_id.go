@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tendermint/tendermint/p2p"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+var showNodeIDCmd = &cobra.Command{
+	Use:   "show_node_id",
+	Short: "Show this node's ID",
+	Run:   showNodeID,
+}
+
+func init() {
+	RootCmd.AddCommand(showNodeIDCmd)
+}
+
+func showNodeID(cmd *cobra.Command, args []string) {
+	nodeKey, err := p2p.LoadNodeKey(config.NodeKeyFile())
+	if err != nil {
+		cmn.Exit(err.Error())
+	}
+	fmt.Println(nodeKey.ID())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/types"
 )
 
@@ -79,7 +80,10 @@ func initMachCoreDirectory(base, mach string) error {
 
 	// Create priv_validator.json file if not present
 	ensurePrivValidator(path.Join(dir, "priv_validator.json"))
-	return nil
+
+	// Create node_key.json file if not present
+	_, err = p2p.LoadOrGenNodeKey(path.Join(dir, "node_key.json"))
+	return err
 
 }
 
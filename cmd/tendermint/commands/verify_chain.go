@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	bc "github.com/tendermint/tendermint/blockchain"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/state/txindex/kv"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+var verifyChainCmd = &cobra.Command{
+	Use:     "verify-chain",
+	Aliases: []string{"verify_chain"},
+	Short:   "Re-execute the blockchain against a fresh app and find where its app hash diverges (node must be stopped)",
+	Long: `Re-execute every block in the block store against a fresh instance of the ABCI app,
+checking the app hash after each block against the next header,
+and each DeliverTx result against the one recorded by the node, if any.
+Reports the first divergent height and tx, if there is one.
+
+The builtin apps start from an empty temporary directory.
+An external app at --proxy_app must be started with no state.`,
+	RunE: verifyChain,
+}
+
+func init() {
+	verifyChainCmd.Flags().String("proxy_app", config.ProxyApp, "Proxy app address, or 'nilapp' or 'dummy' for local testing.")
+	verifyChainCmd.Flags().String("abci", config.ABCI, "Specify abci transport (socket | grpc)")
+	RootCmd.AddCommand(verifyChainCmd)
+}
+
+func verifyChain(cmd *cobra.Command, args []string) error {
+	genDoc, err := types.GenesisDocFromFile(config.GenesisFile())
+	if err != nil {
+		return err
+	}
+
+	blockStore := bc.NewBlockStore(dbm.NewDB("blockstore", config.DBBackend, config.DBDir()))
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := sm.GetState(stateDB, config.GenesisFile())
+	state.SetLogger(logger.With("module", "state"))
+	if config.TxIndex == "kv" {
+		state.TxIndexer = kv.NewTxIndex(dbm.NewDB("tx_index", config.DBBackend, config.DBDir()))
+	}
+
+	// never let a builtin app touch the node's own app state
+	appDir, err := ioutil.TempDir("", "tendermint_verify_chain")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(appDir)
+
+	clientCreator := proxy.DefaultClientCreator(config.ProxyApp, config.ABCI, appDir)
+	proxyApp := proxy.NewAppConns(clientCreator, nil)
+	proxyApp.SetLogger(logger.With("module", "proxy"))
+	if _, err := proxyApp.Start(); err != nil {
+		return errors.New(cmn.Fmt("Error starting proxy app connections: %v", err))
+	}
+	defer proxyApp.Stop()
+
+	res, err := proxyApp.Query().InfoSync()
+	if err != nil {
+		return errors.New(cmn.Fmt("Error calling Info: %v", err))
+	}
+	if res.LastBlockHeight != 0 {
+		return errors.New(cmn.Fmt("App is not fresh, it is at height %d", res.LastBlockHeight))
+	}
+
+	logger.Info("Verifying chain", "storeHeight", blockStore.Height(), "stateHeight", state.LastBlockHeight)
+	div, err := sm.VerifyChain(genDoc, state, blockStore, proxyApp.Consensus(), logger.With("module", "state"))
+	if err != nil {
+		return err
+	}
+	if div != nil {
+		return errors.New(div.String())
+	}
+
+	logger.Info("Verified chain, the app reproduced every block", "height", blockStore.Height())
+	return nil
+}
@@ -70,6 +70,9 @@ type BaseConfig struct {
 	// A JSON file containing the private key to use as a validator in the consensus protocol
 	PrivValidator string `mapstructure:"priv_validator_file"`
 
+	// A JSON file containing the private key to use for p2p authenticated encryption
+	NodeKey string `mapstructure:"node_key_file"`
+
 	// A custom human readable name for this node
 	Moniker string `mapstructure:"moniker"`
 
@@ -110,6 +113,7 @@ func DefaultBaseConfig() BaseConfig {
 	return BaseConfig{
 		Genesis:           "genesis.json",
 		PrivValidator:     "priv_validator.json",
+		NodeKey:           "node_key.json",
 		Moniker:           "anonymous",
 		ProxyApp:          "tcp://127.0.0.1:46658",
 		ABCI:              "socket",
@@ -143,6 +147,11 @@ func (b BaseConfig) PrivValidatorFile() string {
 	return rootify(b.PrivValidator, b.RootDir)
 }
 
+// NodeKeyFile returns the full path to the node_key.json file
+func (b BaseConfig) NodeKeyFile() string {
+	return rootify(b.NodeKey, b.RootDir)
+}
+
 // DBDir returns the full path to the database directory
 func (b BaseConfig) DBDir() string {
 	return rootify(b.DBPath, b.RootDir)
@@ -207,6 +216,18 @@ type P2PConfig struct {
 	// Comma separated list of seed nodes to connect to
 	Seeds string `mapstructure:"seeds"`
 
+	// Comma separated list of nodes to keep persistent connections to
+	PersistentPeers string `mapstructure:"persistent_peers"`
+
+	// Comma separated list of the IDs of peers to keep secret:
+	// they're never added to the address book or gossiped to other peers
+	PrivatePeerIDs string `mapstructure:"private_peer_ids"`
+
+	// Persistent peers are redialed with exponential backoff,
+	// from the min up to the max dial period. In ms
+	PersistentPeersMinDialPeriod int `mapstructure:"persistent_peers_min_dial_period"`
+	PersistentPeersMaxDialPeriod int `mapstructure:"persistent_peers_max_dial_period"`
+
 	// Skip UPNP port forwarding
 	SkipUPNP bool `mapstructure:"skip_upnp"`
 
@@ -219,21 +240,49 @@ type P2PConfig struct {
 	// Set true to enable the peer-exchange reactor
 	PexReactor bool `mapstructure:"pex"`
 
+	// Set true to run as a seed node, which crawls the network to hand out
+	// addresses instead of keeping connections to peers. Requires pex
+	SeedMode bool `mapstructure:"seed_mode"`
+
 	// Maximum number of peers to connect to
 	MaxNumPeers int `mapstructure:"max_num_peers"`
 
+	// Peers whose trust score, from 0 to 1, falls below the threshold
+	// are banned for the ban period. In ms. 0 for either disables banning.
+	// The scores are kept in the address book, so they require pex.
+	// Persistent and private peers are never banned
+	TrustThreshold float64 `mapstructure:"trust_threshold"`
+	BanPeriod      int     `mapstructure:"ban_period"`
+
+	// Maximum number of peers from the same /8, /16, /24 and IP
+	// (/32, /64, /96 and IP for IPv6), in that order. 0 means no limit
+	MaxPeersPerIPRange []int `mapstructure:"max_peers_per_ip_range"`
+
 	// Time to wait before flushing messages out on the connection. In ms
 	FlushThrottleTimeout int `mapstructure:"flush_throttle_timeout"`
+
+	// Limits on the bytes/s sent to and received from each peer,
+	// and on the total for all peers. 0 means no limit
+	SendRate    int64 `mapstructure:"send_rate"`
+	RecvRate    int64 `mapstructure:"recv_rate"`
+	MaxSendRate int64 `mapstructure:"max_send_rate"`
+	MaxRecvRate int64 `mapstructure:"max_recv_rate"`
 }
 
 // DefaultP2PConfig returns a default configuration for the peer-to-peer layer
 func DefaultP2PConfig() *P2PConfig {
 	return &P2PConfig{
-		ListenAddress:        "tcp://0.0.0.0:46656",
-		AddrBook:             "addrbook.json",
-		AddrBookStrict:       true,
-		MaxNumPeers:          50,
-		FlushThrottleTimeout: 100,
+		ListenAddress:                "tcp://0.0.0.0:46656",
+		PersistentPeersMinDialPeriod: 1000,
+		PersistentPeersMaxDialPeriod: 300000,
+		AddrBook:                     "addrbook.json",
+		AddrBookStrict:               true,
+		MaxNumPeers:                  50,
+		TrustThreshold:               0.2,
+		BanPeriod:                    24 * 60 * 60 * 1000,
+		FlushThrottleTimeout:         100,
+		SendRate:                     512000, // 500KB/s
+		RecvRate:                     512000, // 500KB/s
 	}
 }
 
@@ -242,6 +291,8 @@ func TestP2PConfig() *P2PConfig {
 	conf := DefaultP2PConfig()
 	conf.ListenAddress = "tcp://0.0.0.0:36656"
 	conf.SkipUPNP = true
+	conf.PersistentPeersMinDialPeriod = 10
+	conf.PersistentPeersMaxDialPeriod = 100
 	return conf
 }
 
@@ -259,7 +310,32 @@ type MempoolConfig struct {
 	Recheck      bool   `mapstructure:"recheck"`
 	RecheckEmpty bool   `mapstructure:"recheck_empty"`
 	Broadcast    bool   `mapstructure:"broadcast"`
+	AnnounceTxs  bool   `mapstructure:"announce_txs"` // send peers tx hashes, and txs only when they ask for them
 	WalPath      string `mapstructure:"wal_dir"`
+	WalSizeLimit int64  `mapstructure:"wal_size_limit"` // compact the wal when it grows past this size
+	MaxTxs       int    `mapstructure:"max_txs"`        // max number of txs in the mempool, 0 for no limit (the default before 5000)
+	MaxTxsBytes  int64  `mapstructure:"max_txs_bytes"`  // max total size of the txs in the mempool, 0 for no limit
+	MaxTxBytes   int    `mapstructure:"max_tx_bytes"`   // max size of a single tx, 0 for no limit
+
+	// Txs that have not been committed this many blocks, or ms, after they
+	// arrived are evicted. 0 means no limit
+	TTLNumBlocks int `mapstructure:"ttl_num_blocks"`
+	TTLDuration  int `mapstructure:"ttl_duration"`
+
+	// Txs are rechecked in batches of this many, holding the mempool lock
+	// for one batch at a time. 0 means all at once
+	RecheckBatchSize int `mapstructure:"recheck_batch_size"`
+
+	// Limits on the txs each peer can send, per second. Txs over them are
+	// dropped. A peer is disconnected once it sends more than peer_max_spam
+	// dropped or invalid txs in a minute. 0 means no limit
+	PeerMaxTxsRate   int   `mapstructure:"peer_max_txs_rate"`
+	PeerMaxBytesRate int64 `mapstructure:"peer_max_bytes_rate"`
+	PeerMaxSpam      int   `mapstructure:"peer_max_spam"`
+
+	// Limit on the bytes/s of txs gossiped to each peer, so they don't
+	// crowd out consensus msgs on thin links. 0 means no limit
+	GossipSendRate int64 `mapstructure:"gossip_send_rate"`
 }
 
 // DefaultMempoolConfig returns a default configuration for the Tendermint mempool
@@ -268,10 +344,28 @@ func DefaultMempoolConfig() *MempoolConfig {
 		Recheck:      true,
 		RecheckEmpty: true,
 		Broadcast:    true,
+		AnnounceTxs:  false,
 		WalPath:      "data/mempool.wal",
+		WalSizeLimit: 128 * 1024 * 1024, // 128MB
+		MaxTxs:       5000,
+		MaxTxsBytes:  1024 * 1024 * 1024, // 1GB
+		MaxTxBytes:   1024 * 1024,        // 1MB
+		TTLNumBlocks: 0,
+		TTLDuration:  0,
+
+		RecheckBatchSize: 100,
+
+		PeerMaxTxsRate:   1000,
+		PeerMaxBytesRate: 10 * 1024 * 1024, // 10MB
+		PeerMaxSpam:      1000,
 	}
 }
 
+// TTL returns how long a tx can stay in the mempool, or 0 for no limit
+func (m *MempoolConfig) TTL() time.Duration {
+	return time.Duration(m.TTLDuration) * time.Millisecond
+}
+
 // WalDir returns the full path to the mempool's write-ahead log
 func (m *MempoolConfig) WalDir() string {
 	return rootify(m.WalPath, m.RootDir)
@@ -300,10 +394,15 @@ type ConsensusConfig struct {
 	// Make progress as soon as we have all the precommits (as if TimeoutCommit = 0)
 	SkipTimeoutCommit bool `mapstructure:"skip_timeout_commit"`
 
-	// BlockSize
+	// BlockSize. MaxBlockSizeBytes includes the header and commit,
+	// and is at most types.MaxBlockSize
 	MaxBlockSizeTxs   int `mapstructure:"max_block_size_txs"`
 	MaxBlockSizeBytes int `mapstructure:"max_block_size_bytes"`
 
+	// Max total gas wanted by the txs in a block, from their CheckTx TxHints.
+	// -1 means no limit
+	MaxBlockGas int64 `mapstructure:"max_block_gas"`
+
 	// TODO: This probably shouldn't be exposed but it makes it
 	// easy to write tests for the wal/replay
 	BlockPartSize int `mapstructure:"block_part_size"`
@@ -357,7 +456,8 @@ func DefaultConsensusConfig() *ConsensusConfig {
 		TimeoutCommit:               1000,
 		SkipTimeoutCommit:           false,
 		MaxBlockSizeTxs:             10000,
-		MaxBlockSizeBytes:           1,                          // TODO
+		MaxBlockSizeBytes:           types.MaxBlockSize,
+		MaxBlockGas:                 -1,
 		BlockPartSize:               types.DefaultBlockPartSize, // TODO: we shouldnt be importing types
 		PeerGossipSleepDuration:     100,
 		PeerQueryMaj23SleepDuration: 2000,
@@ -40,6 +40,7 @@ laddr = "tcp://0.0.0.0:46657"
 [p2p]
 laddr = "tcp://0.0.0.0:46656"
 seeds = ""
+persistent_peers = ""
 `
 
 func defaultConfig(moniker string) string {
@@ -103,6 +104,7 @@ laddr = "tcp://0.0.0.0:36657"
 [p2p]
 laddr = "tcp://0.0.0.0:36656"
 seeds = ""
+persistent_peers = ""
 `
 
 func testConfig(moniker string) (testConfig string) {
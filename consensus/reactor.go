@@ -44,6 +44,7 @@ func NewConsensusReactor(consensusState *ConsensusState, fastSync bool) *Consens
 		fastSync: fastSync,
 	}
 	conR.BaseReactor = *p2p.NewBaseReactor("ConsensusReactor", conR)
+	consensusState.SetPeerEventFunc(conR.reportPeerEvent)
 	return conR
 }
 
@@ -290,6 +291,14 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	}
 }
 
+// reportPeerEvent reports what the peer's msg was like to the switch,
+// if the peer is still connected.
+func (conR *ConsensusReactor) reportPeerEvent(peerKey string, event p2p.PeerEvent) {
+	if peer := conR.Switch.Peers().Get(peerKey); peer != nil {
+		conR.Switch.ReportPeerEvent(peer, event)
+	}
+}
+
 // SetEventSwitch implements events.Eventable
 func (conR *ConsensusReactor) SetEventSwitch(evsw types.EventSwitch) {
 	conR.evsw = evsw
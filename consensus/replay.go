@@ -16,6 +16,7 @@ import (
 	cmn "github.com/tendermint/tmlibs/common"
 	"github.com/tendermint/tmlibs/log"
 
+	"github.com/tendermint/tendermint/fail"
 	"github.com/tendermint/tendermint/proxy"
 	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
@@ -198,6 +199,8 @@ func makeHeightSearchFunc(height int) auto.SearchFunc {
 // by handshaking with the app to figure out where
 // we were last and using the WAL to recover there
 
+var failHandshakeReplayBlock = fail.Point("consensus/Handshaker/replayBlock")
+
 type Handshaker struct {
 	state  *sm.State
 	store  types.BlockStore
@@ -308,7 +311,11 @@ func (h *Handshaker) ReplayBlocks(appHash []byte, appBlockHeight int, proxyApp p
 			return h.replayBlock(storeBlockHeight, proxyApp.Consensus())
 
 		} else if appBlockHeight == storeBlockHeight {
-			// We ran Commit, but didn't save the state, so replayBlock with mock app
+			// We ran Commit, but didn't save the state, so replayBlock with mock app.
+			// We only run Commit after writing the commit marker, so it must be there.
+			if !h.state.CommitPending() {
+				return appHash, sm.ErrNoCommitMarker{storeBlockHeight}
+			}
 			abciResponses := h.state.LoadABCIResponses()
 			mockApp := newMockProxyApp(appHash, abciResponses)
 			h.logger.Info("Replay last block using mock app")
@@ -361,6 +368,8 @@ func (h *Handshaker) replayBlock(height int, proxyApp proxy.AppConnConsensus) ([
 	block := h.store.LoadBlock(height)
 	meta := h.store.LoadBlockMeta(height)
 
+	fail.Fail(failHandshakeReplayBlock)
+
 	if err := h.state.ApplyBlock(eventCache, proxyApp, block, meta.BlockID.PartsHeader, mempool); err != nil {
 		return nil, err
 	}
@@ -383,6 +383,54 @@ func testHandshakeReplay(t *testing.T, nBlocks int, mode uint) {
 	}
 }
 
+// The app committed the last block, but there is no commit marker for it
+func TestHandshakeErrWithoutCommitMarker(t *testing.T) {
+	config := ResetConfig("proxy_test_")
+
+	walBody, err := cmn.ReadFile(path.Join(data_dir, "many_blocks.cswal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	walFile := writeWAL(string(walBody))
+	config.Consensus.SetWalFile(walFile)
+
+	privVal := types.LoadPrivValidator(config.PrivValidatorFile())
+	testPartSize = config.Consensus.BlockPartSize
+
+	wal, err := NewWAL(walFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.SetLogger(log.TestingLogger())
+	if _, err := wal.Start(); err != nil {
+		t.Fatal(err)
+	}
+	chain, commits, err := makeBlockchainFromWAL(wal)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, store := stateAndStore(config, privVal.PubKey)
+	store.chain = chain
+	store.commits = commits
+
+	// the state is one block behind the store and the app
+	buildTMStateFromChain(config, state, chain, 2)
+	dummyApp := dummy.NewPersistentDummyApplication(path.Join(config.DBDir(), "2"))
+	clientCreator := proxy.NewLocalClientCreator(dummyApp)
+	appState, _ := stateAndStore(config, privVal.PubKey)
+	buildAppStateFromChain(proxy.NewAppConns(clientCreator, nil), appState, chain, NUM_BLOCKS, 2)
+
+	// roll the commit marker back, as if the app committed before we wrote it
+	state.SaveABCIResponses(&sm.ABCIResponses{Height: state.LastBlockHeight})
+
+	handshaker := NewHandshaker(state, store)
+	proxyApp := proxy.NewAppConns(clientCreator, handshaker)
+	if _, err := proxyApp.Start(); err == nil {
+		t.Fatal("Expected handshake to fail without a commit marker")
+	}
+}
+
 func applyBlock(st *sm.State, blk *types.Block, proxyApp proxy.AppConns) {
 	err := st.ApplyBlock(nil, proxyApp.Consensus(), blk, blk.MakePartSet(testPartSize).Header(), mempool)
 	if err != nil {
@@ -591,6 +639,14 @@ func (bs *mockBlockStore) LoadBlockMeta(height int) *types.BlockMeta {
 	}
 }
 func (bs *mockBlockStore) LoadBlockPart(height int, index int) *types.Part { return nil }
+func (bs *mockBlockStore) LoadBlockHeightByHash(hash []byte) int {
+	for i, block := range bs.chain {
+		if bytes.Equal(block.Hash(), hash) {
+			return i + 1
+		}
+	}
+	return 0
+}
 func (bs *mockBlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit) {
 }
 func (bs *mockBlockStore) LoadBlockCommit(height int) *types.Commit {
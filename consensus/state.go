@@ -9,9 +9,10 @@ import (
 	"sync"
 	"time"
 
-	fail "github.com/ebuchman/fail-test"
 	wire "github.com/tendermint/go-wire"
 	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/fail"
+	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/proxy"
 	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
@@ -32,6 +33,18 @@ var (
 	ErrVoteHeightMismatch       = errors.New("Error vote height mismatch")
 )
 
+//-----------------------------------------------------------------------------
+// Crash points for testing recovery, see package fail.
+
+var (
+	failFinalizeCommitBeforeSaveBlock = fail.Point("consensus/finalizeCommit/beforeSaveBlock")
+	failFinalizeCommitAfterSaveBlock  = fail.Point("consensus/finalizeCommit/afterSaveBlock")
+	failFinalizeCommitAfterEndHeight  = fail.Point("consensus/finalizeCommit/afterEndHeight")
+	failFinalizeCommitAfterApplyBlock = fail.Point("consensus/finalizeCommit/afterApplyBlock")
+	failFinalizeCommitAfterFireEvents = fail.Point("consensus/finalizeCommit/afterFireEvents")
+	failFinalizeCommitAfterUpdate     = fail.Point("consensus/finalizeCommit/afterUpdateToState")
+)
+
 //-----------------------------------------------------------------------------
 // RoundStepType enum type
 
@@ -201,6 +214,9 @@ type ConsensusState struct {
 	// and to notify external subscribers, eg. through a websocket
 	evsw types.EventSwitch
 
+	// reports what peers' msgs were like, for their trust metrics
+	peerEventFunc func(peerKey string, event p2p.PeerEvent)
+
 	// a Write-Ahead Log ensures we can recover from any kind of crash
 	// and helps us avoid signing conflicting votes
 	wal        *WAL
@@ -256,6 +272,11 @@ func (cs *ConsensusState) SetEventSwitch(evsw types.EventSwitch) {
 	cs.evsw = evsw
 }
 
+// SetPeerEventFunc sets the function to report good and bad msgs from peers to.
+func (cs *ConsensusState) SetPeerEventFunc(f func(peerKey string, event p2p.PeerEvent)) {
+	cs.peerEventFunc = f
+}
+
 func (cs *ConsensusState) String() string {
 	// better not to access shared variables
 	return cmn.Fmt("ConsensusState") //(H:%v R:%v S:%v", cs.Height, cs.Round, cs.Step)
@@ -641,16 +662,25 @@ func (cs *ConsensusState) handleMsg(mi msgInfo, rs RoundState) {
 		err = cs.setProposal(msg.Proposal)
 	case *BlockPartMessage:
 		// if the proposal is complete, we'll enterPrevote or tryFinalizeCommit
-		_, err = cs.addProposalBlockPart(msg.Height, msg.Part, peerKey != "")
+		var added bool
+		added, err = cs.addProposalBlockPart(msg.Height, msg.Part, peerKey != "")
+		if err == types.ErrPartSetInvalidProof {
+			cs.reportPeerEvent(peerKey, p2p.PeerEventInvalidBlock)
+		} else if added {
+			cs.reportPeerEvent(peerKey, p2p.PeerEventUsefulData)
+		}
 		if err != nil && msg.Round != cs.Round {
 			err = nil
 		}
 	case *VoteMessage:
 		// attempt to add the vote and dupeout the validator if its a duplicate signature
 		// if the vote gives us a 2/3-any or 2/3-one, we transition
-		err := cs.tryAddVote(msg.Vote, peerKey)
+		added, err := cs.tryAddVote(msg.Vote, peerKey)
 		if err == ErrAddingVote {
-			// TODO: punish peer
+			cs.reportPeerEvent(peerKey, p2p.PeerEventBadVote)
+		} else if added {
+			// only new votes count, so peers can't earn trust by replaying them
+			cs.reportPeerEvent(peerKey, p2p.PeerEventUsefulData)
 		}
 
 		// NOTE: the vote is broadcast to peers by the reactor listening
@@ -667,6 +697,13 @@ func (cs *ConsensusState) handleMsg(mi msgInfo, rs RoundState) {
 	}
 }
 
+// reportPeerEvent reports the event if the msg was from a peer.
+func (cs *ConsensusState) reportPeerEvent(peerKey string, event p2p.PeerEvent) {
+	if peerKey != "" && cs.peerEventFunc != nil {
+		cs.peerEventFunc(peerKey, event)
+	}
+}
+
 func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 	cs.Logger.Debug("Received tock", "timeout", ti.Duration, "height", ti.Height, "round", ti.Round, "step", ti.Step)
 
@@ -870,8 +907,23 @@ func (cs *ConsensusState) createProposalBlock() (block *types.Block, blockParts
 		return
 	}
 
-	// Mempool validated transactions
-	txs := cs.mempool.Reap(cs.config.MaxBlockSizeTxs)
+	// Mempool validated transactions, as many as fit in the block
+	// with the header and commit
+	emptyBlock, _ := types.MakeBlock(cs.Height, cs.state.ChainID, nil, commit,
+		cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, cs.config.BlockPartSize)
+	maxBytes := cs.config.MaxBlockSizeBytes
+	if maxBytes <= 0 || maxBytes > types.MaxBlockSize {
+		maxBytes = types.MaxBlockSize
+	}
+	maxTxsBytes := emptyBlock.MaxTxsBytes(maxBytes)
+	if maxTxsBytes < 0 {
+		cs.Logger.Error("enterPropose: The header and commit are larger than the max block size", "maxBytes", maxBytes)
+		maxTxsBytes = 0
+	}
+	txs := cs.mempool.ReapMaxBytesMaxGas(int64(maxTxsBytes), cs.config.MaxBlockGas)
+	if maxTxs := cs.config.MaxBlockSizeTxs; maxTxs >= 0 && len(txs) > maxTxs {
+		txs = txs[:maxTxs]
+	}
 
 	return types.MakeBlock(cs.Height, cs.state.ChainID, txs, commit,
 		cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, cs.config.BlockPartSize)
@@ -1179,7 +1231,18 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 		"height", block.Height, "hash", block.Hash(), "root", block.AppHash)
 	cs.Logger.Info(cmn.Fmt("%v", block))
 
-	fail.Fail() // XXX
+	// The commit sequence below persists, in order:
+	//   1. the block, to the blockStore (one batch)
+	//   2. #ENDHEIGHT, to the WAL
+	//   3. the ABCIResponses and the commit marker, to the stateDB (one batch)
+	//   4. the app state, by app.Commit
+	//   5. the State, to the stateDB
+	// 3-5 happen in ApplyBlock. A crash before 1 is recovered by the WAL;
+	// a crash after 1 is recovered by the Handshaker on restart,
+	// which tells 3, 4 and 5 apart by the commit marker and the app height.
+	// See TestCrashRecovery in the node package.
+
+	fail.Fail(failFinalizeCommitBeforeSaveBlock)
 
 	// Save to blockStore.
 	if cs.blockStore.Height() < block.Height {
@@ -1193,7 +1256,7 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 		cs.Logger.Info("Calling finalizeCommit on already stored block", "height", block.Height)
 	}
 
-	fail.Fail() // XXX
+	fail.Fail(failFinalizeCommitAfterSaveBlock)
 
 	// Finish writing to the WAL for this height.
 	// NOTE: If we fail before writing this, we'll never write it,
@@ -1206,7 +1269,7 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 		cs.wal.writeEndHeight(height)
 	}
 
-	fail.Fail() // XXX
+	fail.Fail(failFinalizeCommitAfterEndHeight)
 
 	// Create a copy of the state for staging
 	// and an event cache for txs
@@ -1222,7 +1285,7 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 		return
 	}
 
-	fail.Fail() // XXX
+	fail.Fail(failFinalizeCommitAfterApplyBlock)
 
 	// Fire event for new block.
 	// NOTE: If we fail before firing, these events will never fire
@@ -1235,12 +1298,12 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 	types.FireEventNewBlockHeader(cs.evsw, types.EventDataNewBlockHeader{block.Header})
 	eventCache.Flush()
 
-	fail.Fail() // XXX
+	fail.Fail(failFinalizeCommitAfterFireEvents)
 
 	// NewHeightStep!
 	cs.updateToState(stateCopy)
 
-	fail.Fail() // XXX
+	fail.Fail(failFinalizeCommitAfterUpdate)
 
 	// cs.StartTime is already set.
 	// Schedule Round0 to start soon.
@@ -1324,31 +1387,31 @@ func (cs *ConsensusState) addProposalBlockPart(height int, part *types.Part, ver
 }
 
 // Attempt to add the vote. if its a duplicate signature, dupeout the validator
-func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) error {
-	_, err := cs.addVote(vote, peerKey)
+func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) (bool, error) {
+	added, err := cs.addVote(vote, peerKey)
 	if err != nil {
 		// If the vote height is off, we'll just ignore it,
 		// But if it's a conflicting sig, broadcast evidence tx for slashing.
 		// If it's otherwise invalid, punish peer.
 		if err == ErrVoteHeightMismatch {
-			return err
+			return added, err
 		} else if _, ok := err.(*types.ErrVoteConflictingVotes); ok {
 			if bytes.Equal(vote.ValidatorAddress, cs.privValidator.GetAddress()) {
 				cs.Logger.Error("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", "height", vote.Height, "round", vote.Round, "type", vote.Type)
-				return err
+				return added, err
 			}
 			cs.Logger.Error("Found conflicting vote. Publish evidence (TODO)", "height", vote.Height, "round", vote.Round, "type", vote.Type, "valAddr", vote.ValidatorAddress, "valIndex", vote.ValidatorIndex)
 
 			// TODO: track evidence for inclusion in a block
 
-			return err
+			return added, err
 		} else {
 			// Probably an invalid signature. Bad peer.
 			cs.Logger.Error("Error attempting to add vote", "err", err)
-			return ErrAddingVote
+			return added, ErrAddingVote
 		}
 	}
-	return nil
+	return added, nil
 }
 
 //-----------------------------------------------------------------------------
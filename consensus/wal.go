@@ -4,6 +4,7 @@ import (
 	"time"
 
 	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/fail"
 	"github.com/tendermint/tendermint/types"
 	auto "github.com/tendermint/tmlibs/autofile"
 	. "github.com/tendermint/tmlibs/common"
@@ -29,6 +30,8 @@ var _ = wire.RegisterInterface(
 //--------------------------------------------------------
 // Simple write-ahead logger
 
+var failWALSaveBeforeWrite = fail.Point("consensus/WAL/Save/beforeWrite")
+
 // Write ahead logger writes msgs to disk before they are processed.
 // Can be used for crash-recovery and deterministic replay
 // TODO: currently the wal is overwritten during replay catchup
@@ -82,6 +85,8 @@ func (wal *WAL) Save(wmsg WALMessage) {
 			}
 		}
 	}
+	fail.Fail(failWALSaveBeforeWrite)
+
 	// Write the wal message
 	var wmsgBytes = wire.JSONBytes(TimedWALMessage{time.Now(), wmsg})
 	err := wal.group.WriteLine(string(wmsgBytes))
@@ -0,0 +1,98 @@
+// Package fail provides named crash points for testing recovery from a crash
+// at well defined places in the block commit and persistence code.
+//
+// A fail point is registered with Point and triggered with Fail.
+// All fail points are no-ops unless the environment variable TM_FAIL_POINT
+// names one of them, in which case the process exits with ExitCode the n-th
+// time that point is reached:
+//
+//	TM_FAIL_POINT=state/ApplyBlock/afterExec      # crash the first time
+//	TM_FAIL_POINT=state/ApplyBlock/afterExec:3    # crash the third time
+package fail
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvFailPoint is the environment variable holding the fail point to crash at.
+	EnvFailPoint = "TM_FAIL_POINT"
+
+	// ExitCode is the exit code of a process that crashed at a fail point,
+	// so test harnesses can tell it apart from other failures.
+	ExitCode = 99
+)
+
+var (
+	mtx    sync.Mutex
+	points = make(map[string]struct{})
+
+	target     string // name of the enabled fail point, if any
+	targetHits int    // crash on this hit of the target
+	hits       int    // number of times the target was reached
+)
+
+func init() {
+	target, targetHits = parse(os.Getenv(EnvFailPoint))
+}
+
+// parse splits "name[:n]" into the fail point name and the hit to crash on.
+func parse(s string) (string, int) {
+	if s == "" {
+		return "", 0
+	}
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, 1
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n < 1 {
+		return s, 1
+	}
+	return s[:i], n
+}
+
+// Point registers a named fail point and returns its name.
+// It is meant to be used to declare package level variables.
+func Point(name string) string {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if _, ok := points[name]; ok {
+		panic(fmt.Sprintf("fail point %s registered twice", name))
+	}
+	points[name] = struct{}{}
+	return name
+}
+
+// Points returns the names of all registered fail points, sorted.
+func Points() []string {
+	mtx.Lock()
+	defer mtx.Unlock()
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fail exits the process if name is the enabled fail point
+// and it has been reached the configured number of times.
+func Fail(name string) {
+	if target == "" || name != target {
+		return
+	}
+	mtx.Lock()
+	hits++
+	crash := hits == targetHits
+	mtx.Unlock()
+	if crash {
+		fmt.Fprintf(os.Stderr, "*** fail-test: crashing at %s (hit %d) ***\n", name, targetHits)
+		os.Exit(ExitCode)
+	}
+}
@@ -0,0 +1,42 @@
+package fail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		in   string
+		name string
+		hits int
+	}{
+		{"", "", 0},
+		{"a/b/c", "a/b/c", 1},
+		{"a/b/c:3", "a/b/c", 3},
+		{"a/b/c:0", "a/b/c:0", 1},
+		{"a/b/c:x", "a/b/c:x", 1},
+	}
+	for _, c := range cases {
+		name, hits := parse(c.in)
+		assert.Equal(c.name, name, c.in)
+		assert.Equal(c.hits, hits, c.in)
+	}
+}
+
+func TestPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	b := Point("test/b")
+	a := Point("test/a")
+	assert.Equal("test/a", a)
+	assert.Equal("test/b", b)
+	assert.Equal([]string{"test/a", "test/b"}, Points())
+	assert.Panics(func() { Point("test/a") })
+
+	// not enabled, so this is a no-op
+	Fail(a)
+}
@@ -0,0 +1,44 @@
+package mempool
+
+import (
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+type (
+	// ErrTxTooLarge means the tx is larger than the mempool's max_tx_bytes.
+	ErrTxTooLarge struct {
+		Max    int
+		Actual int
+	}
+
+	// ErrMempoolIsFull means the mempool has reached its max_txs or max_txs_bytes,
+	// and the tx did not have a high enough priority to evict any txs.
+	ErrMempoolIsFull struct {
+		NumTxs      int
+		MaxTxs      int
+		TxsBytes    int64
+		MaxTxsBytes int64
+	}
+
+	// ErrPeerSpam means a peer sent more txs that were over its rate limits
+	// or failed CheckTx than its peer_max_spam allows.
+	ErrPeerSpam struct {
+		Stats types.MempoolPeerStats
+	}
+)
+
+func (e ErrTxTooLarge) Error() string {
+	return cmn.Fmt("Tx too large. Max size is %d, but got %d", e.Max, e.Actual)
+}
+
+func (e ErrMempoolIsFull) Error() string {
+	return cmn.Fmt("Mempool is full: number of txs %d (max: %d), total txs bytes %d (max: %d)",
+		e.NumTxs, e.MaxTxs, e.TxsBytes, e.MaxTxsBytes)
+}
+
+func (e ErrPeerSpam) Error() string {
+	return cmn.Fmt("Peer sent too many bad txs: %d txs, %d invalid, %d over the rate limits",
+		e.Stats.Txs, e.Stats.Invalid, e.Stats.Throttled)
+}
@@ -1,14 +1,11 @@
 package mempool
 
 import (
-	"bytes"
 	"container/list"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/pkg/errors"
-
 	abci "github.com/tendermint/abci/types"
 	auto "github.com/tendermint/tmlibs/autofile"
 	"github.com/tendermint/tmlibs/clist"
@@ -24,7 +21,10 @@ import (
 
 The mempool pushes new txs onto the proxyAppConn.
 It gets a stream of (req, res) tuples from the proxy.
-The memool stores good txs in a concurrent linked-list.
+The memool stores good txs in a concurrent linked-list, in arrival order.
+It also keeps an index of the txs by the priority the app gave them in CheckTx
+(see TxHints), which Reap() takes txs from and which decides what to evict
+when the mempool is full.
 
 Multiple concurrent go-routines can traverse this linked-list
 safely by calling .NextWait() on each element.
@@ -34,6 +34,7 @@ So we have several go-routines:
 2. Many mempool reactor's peer routines calling CheckTx()
 3. Many mempool reactor's peer routines traversing the txs linked list
 4. Another goroutine calling GarbageCollectTxs() periodically
+5. A goroutine rechecking txs in the background after Update() (see recheck.go)
 
 To manage these goroutines, there are three methods of locking.
 1. Mutations to the linked-list is protected by an internal mtx (CList is goroutine-safe)
@@ -50,38 +51,49 @@ TODO: Better handle abci client errors. (make it automatically handle connection
 
 const cacheSize = 100000
 
+// the Log of the CheckTx response for a tx in the cache
+const duplicateTxLog = "Duplicate transaction (ignored)"
+
 type Mempool struct {
 	config *cfg.MempoolConfig
 
 	proxyMtx      sync.Mutex
 	proxyAppConn  proxy.AppConnMempool
-	txs           *clist.CList    // concurrent linked-list of good txs
-	counter       int64           // simple incrementing counter
-	height        int             // the last block Update()'d to
-	rechecking    int32           // for re-checking filtered txs on Update()
-	recheckCursor *clist.CElement // next expected response
-	recheckEnd    *clist.CElement // re-checking stops here
+	txs           *clist.CList     // concurrent linked-list of good txs
+	txsByPriority *txPriorityIndex // the elements of txs, by priority
+	txsBytes      int64            // total size of txs, in bytes
+	prioritized   int32            // set once the app gives a tx a priority
+	counter       int64            // simple incrementing counter
+	height        int              // the last block Update()'d to
+	rechecking    int32            // number of txs the recheck has yet to handle, see recheck.go
 
 	// Keep a cache of already-seen txs.
 	// This reduces the pressure on the proxyApp.
 	cache *txCache
 
-	// A log of mempool txs
-	wal *auto.AutoFile
+	// A log of mempool txs, see wal.go.
+	// walMtx guards the wal, since txs are removed from it in ABCI callbacks
+	// that don't hold proxyMtx
+	walMtx         sync.Mutex
+	wal            *auto.AutoFile
+	walCompactSize int64 // size of the wal after it was last compacted
 
+	evsw   types.EventSwitch
 	logger log.Logger
 }
 
-func NewMempool(config *cfg.MempoolConfig, proxyAppConn proxy.AppConnMempool) *Mempool {
+// NewMempool returns a mempool for a chain at the given height,
+// which is the height txs arriving before the first Update() are recorded at.
+// Call ReplayWAL() to recover the txs from before a restart.
+func NewMempool(config *cfg.MempoolConfig, proxyAppConn proxy.AppConnMempool, height int) *Mempool {
 	mempool := &Mempool{
 		config:        config,
 		proxyAppConn:  proxyAppConn,
 		txs:           clist.New(),
+		txsByPriority: newTxPriorityIndex(),
 		counter:       0,
-		height:        0,
+		height:        height,
 		rechecking:    0,
-		recheckCursor: nil,
-		recheckEnd:    nil,
 		logger:        log.NewNopLogger(),
 		cache:         newTxCache(cacheSize),
 	}
@@ -95,19 +107,9 @@ func (mem *Mempool) SetLogger(l log.Logger) {
 	mem.logger = l
 }
 
-func (mem *Mempool) initWAL() {
-	walDir := mem.config.WalDir()
-	if walDir != "" {
-		err := cmn.EnsureDir(walDir, 0700)
-		if err != nil {
-			cmn.PanicSanity(errors.Wrap(err, "Error ensuring Mempool wal dir"))
-		}
-		af, err := auto.OpenAutoFile(walDir + "/wal")
-		if err != nil {
-			cmn.PanicSanity(errors.Wrap(err, "Error opening Mempool wal file"))
-		}
-		mem.wal = af
-	}
+// SetEventSwitch implements events.Eventable
+func (mem *Mempool) SetEventSwitch(evsw types.EventSwitch) {
+	mem.evsw = evsw
 }
 
 // consensus must be able to hold lock to safely update
@@ -124,6 +126,59 @@ func (mem *Mempool) Size() int {
 	return mem.txs.Len()
 }
 
+// Total size of the transactions in the mempool clist, in bytes
+func (mem *Mempool) TxsBytes() int64 {
+	return atomic.LoadInt64(&mem.txsBytes)
+}
+
+// TxByHash returns the tx in the mempool with the given hash, or nil if there is none.
+func (mem *Mempool) TxByHash(hash []byte) types.Tx {
+	if e := mem.txsByPriority.GetByHash(hash); e != nil {
+		return e.Value.(*mempoolTx).tx
+	}
+	return nil
+}
+
+// TxInfo returns the tx in the mempool with the given hash,
+// and what the mempool knows about it, or nil if there is no such tx.
+func (mem *Mempool) TxInfo(hash []byte) *types.MempoolTxInfo {
+	// the priority is guarded by the index
+	idx := mem.txsByPriority
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	e := idx.hashes[string(hash)]
+	if e == nil {
+		return nil
+	}
+	memTx := e.Value.(*mempoolTx)
+	return &types.MempoolTxInfo{
+		Tx:        memTx.tx,
+		Height:    memTx.Height(),
+		Time:      memTx.timestamp,
+		CheckTx:   memTx.checkTx,
+		Priority:  memTx.priority,
+		GasWanted: memTx.gasWanted,
+	}
+}
+
+// RemoveTx removes the tx with the given hash from the mempool.
+// It returns false if there is no such tx.
+// The tx stays in the cache, so peers can't add it back right away.
+func (mem *Mempool) RemoveTx(hash []byte) bool {
+	mem.proxyMtx.Lock()
+	defer mem.proxyMtx.Unlock()
+
+	e := mem.txsByPriority.GetByHash(hash)
+	if e == nil || !mem.removeTx(e) {
+		return false
+	}
+	memTx := e.Value.(*mempoolTx)
+	mem.logger.Info("Removing tx", "tx", memTx.tx)
+	types.FireEventEvictTx(mem.evsw, types.EventDataEvictTx{memTx.tx, "removed"})
+	return true
+}
+
 // Remove all transactions from mempool and cache
 func (mem *Mempool) Flush() {
 	mem.proxyMtx.Lock()
@@ -131,9 +186,9 @@ func (mem *Mempool) Flush() {
 
 	mem.cache.Reset()
 
+	// the recheck may be removing txs too
 	for e := mem.txs.Front(); e != nil; e = e.Next() {
-		mem.txs.Remove(e)
-		e.DetachPrev()
+		mem.removeTx(e)
 	}
 }
 
@@ -148,33 +203,56 @@ func (mem *Mempool) TxsFrontWait() *clist.CElement {
 // cb: A callback from the CheckTx command.
 //     It gets called from another goroutine.
 // CONTRACT: Either cb will get called, or err returned.
+// Returns ErrTxTooLarge if the tx is over max_tx_bytes.
+// Returns ErrMempoolIsFull if the mempool is full and the app does not give
+// txs priorities, so the tx could not evict any; otherwise a tx that does not
+// fit is rejected after CheckTx, with the error in the response passed to cb.
 func (mem *Mempool) CheckTx(tx types.Tx, cb func(*abci.Response)) (err error) {
+	return mem.CheckTxFromPeer(tx, "", cb)
+}
+
+// CheckTxFromPeer is CheckTx for a tx received from the peer with the given key.
+// The mempool remembers the peers that sent it each tx,
+// so the reactor does not send the tx back to them.
+func (mem *Mempool) CheckTxFromPeer(tx types.Tx, peerKey string, cb func(*abci.Response)) (err error) {
 	mem.proxyMtx.Lock()
 	defer mem.proxyMtx.Unlock()
 
+	if maxTxBytes := mem.config.MaxTxBytes; maxTxBytes > 0 && len(tx) > maxTxBytes {
+		err := ErrTxTooLarge{maxTxBytes, len(tx)}
+		mem.fireRejectTx(tx, abci.CodeType_InternalError, err.Error())
+		return err
+	}
 	// CACHE
 	if mem.cache.Exists(tx) {
+		if peerKey != "" {
+			if e := mem.txsByPriority.Get(tx); e != nil {
+				e.Value.(*mempoolTx).addPeer(peerKey)
+			}
+		}
 		if cb != nil {
 			cb(&abci.Response{
 				Value: &abci.Response_CheckTx{
 					&abci.ResponseCheckTx{
 						Code: abci.CodeType_BadNonce, // TODO or duplicate tx
-						Log:  "Duplicate transaction (ignored)",
+						Log:  duplicateTxLog,
 					},
 				},
 			})
 		}
 		return nil
 	}
+	// a duplicate is reported as one above even when the mempool is full
+	if atomic.LoadInt32(&mem.prioritized) == 0 && mem.isFull(len(tx)) {
+		err := mem.errMempoolIsFull()
+		mem.fireRejectTx(tx, abci.CodeType_InternalError, err.Error())
+		return err
+	}
 	mem.cache.Push(tx)
 	// END CACHE
 
 	// WAL
-	if mem.wal != nil {
-		// TODO: Notify administrators when WAL fails
-		mem.wal.Write([]byte(tx))
-		mem.wal.Write([]byte("\n"))
-	}
+	mem.writeWAL(tx)
 	// END WAL
 
 	// NOTE: proxyAppConn may error if tx buffer is full
@@ -182,39 +260,82 @@ func (mem *Mempool) CheckTx(tx types.Tx, cb func(*abci.Response)) (err error) {
 		return err
 	}
 	reqRes := mem.proxyAppConn.CheckTxAsync(tx)
-	if cb != nil {
-		reqRes.SetCallback(cb)
+	// handle the response here rather than in resCb,
+	// so the mempool is done with it before cb sees it
+	reqRes.SetCallback(func(res *abci.Response) {
+		mem.resCbNormal(reqRes.Request, res, peerKey)
+		if cb != nil {
+			cb(res)
+		}
+	})
+
+	if mem.walNeedsRotation() {
+		// make sure txs in flight are in the mempool before rewriting the wal
+		mem.proxyAppConn.FlushSync()
+		mem.compactWAL()
 	}
 
 	return nil
 }
 
-// ABCI callback function
-func (mem *Mempool) resCb(req *abci.Request, res *abci.Response) {
-	if mem.recheckCursor == nil {
-		mem.resCbNormal(req, res)
-	} else {
-		mem.resCbRecheck(req, res)
-	}
-}
+// ABCI callback function for all responses on the mempool connection.
+// CheckTx() and recheckTxs() handle their responses in callbacks of their own,
+// so there is nothing left to do here.
+func (mem *Mempool) resCb(req *abci.Request, res *abci.Response) {}
 
-func (mem *Mempool) resCbNormal(req *abci.Request, res *abci.Response) {
+func (mem *Mempool) resCbNormal(req *abci.Request, res *abci.Response, peerKey string) {
 	switch r := res.Value.(type) {
 	case *abci.Response_CheckTx:
 		if r.CheckTx.Code == abci.CodeType_OK {
+			tx := req.GetCheckTx().Tx
+			if e := mem.txsByPriority.Get(tx); e != nil {
+				// the cache dropped the tx while it was still in the mempool
+				if peerKey != "" {
+					e.Value.(*mempoolTx).addPeer(peerKey)
+				}
+				r.CheckTx.Code = abci.CodeType_BadNonce // TODO or duplicate tx
+				r.CheckTx.Log = duplicateTxLog
+				return
+			}
+			hints := mem.parseTxHints(r.CheckTx)
+			if err := mem.makeRoomFor(len(tx), hints); err != nil {
+				mem.logger.Info("Rejected good transaction", "err", err)
+				// let the sender know why
+				r.CheckTx.Code = abci.CodeType_InternalError
+				r.CheckTx.Log = err.Error()
+
+				// remove from cache (it might be good later)
+				mem.cache.Remove(tx)
+				mem.fireRejectTx(tx, r.CheckTx.Code, r.CheckTx.Log)
+				return
+			}
 			mem.counter++
 			memTx := &mempoolTx{
-				counter: mem.counter,
-				height:  int64(mem.height),
-				tx:      req.GetCheckTx().Tx,
+				counter:   mem.counter,
+				height:    int64(mem.height),
+				timestamp: time.Now(),
+				tx:        tx,
+				priority:  hints.Priority,
+				sender:    hints.Sender,
+				keys:      hints.Keys,
+				gasWanted: hints.GasWanted,
+				checkTx:   r.CheckTx.Result(),
+				peers:     cmn.NewCMap(),
+			}
+			if peerKey != "" {
+				memTx.addPeer(peerKey)
 			}
-			mem.txs.PushBack(memTx)
+			e := mem.txs.PushBack(memTx)
+			mem.txsByPriority.Add(e)
+			atomic.AddInt64(&mem.txsBytes, int64(len(tx)))
+			types.FireEventAddTx(mem.evsw, types.EventDataAddTx{tx})
 		} else {
 			// ignore bad transaction
 			mem.logger.Info("Bad Transaction", "res", r)
 
 			// remove from cache (it might be good later)
 			mem.cache.Remove(req.GetCheckTx().Tx)
+			mem.fireRejectTx(req.GetCheckTx().Tx, r.CheckTx.Code, r.CheckTx.Log)
 
 			// TODO: handle other retcodes
 		}
@@ -223,77 +344,127 @@ func (mem *Mempool) resCbNormal(req *abci.Request, res *abci.Response) {
 	}
 }
 
-func (mem *Mempool) resCbRecheck(req *abci.Request, res *abci.Response) {
-	switch r := res.Value.(type) {
-	case *abci.Response_CheckTx:
-		memTx := mem.recheckCursor.Value.(*mempoolTx)
-		if !bytes.Equal(req.GetCheckTx().Tx, memTx.tx) {
-			cmn.PanicSanity(cmn.Fmt("Unexpected tx response from proxy during recheck\n"+
-				"Expected %X, got %X", r.CheckTx.Data, memTx.tx))
-		}
-		if r.CheckTx.Code == abci.CodeType_OK {
-			// Good, nothing to do.
-		} else {
-			// Tx became invalidated due to newly committed block.
-			mem.txs.Remove(mem.recheckCursor)
-			mem.recheckCursor.DetachPrev()
+func (mem *Mempool) fireRejectTx(tx types.Tx, code abci.CodeType, log string) {
+	types.FireEventRejectTx(mem.evsw, types.EventDataRejectTx{tx, code, log})
+}
 
-			// remove from cache (it might be good later)
-			mem.cache.Remove(req.GetCheckTx().Tx)
-		}
-		if mem.recheckCursor == mem.recheckEnd {
-			mem.recheckCursor = nil
-		} else {
-			mem.recheckCursor = mem.recheckCursor.Next()
+// parseTxHints returns the hints in a CheckTx response,
+// or the zero TxHints if they are malformed.
+func (mem *Mempool) parseTxHints(res *abci.ResponseCheckTx) TxHints {
+	hints, err := ParseTxHints(res.Data)
+	if err != nil {
+		mem.logger.Error("Malformed tx hints in CheckTx response", "data", res.Data, "err", err)
+	}
+	if hints.Priority != 0 {
+		atomic.StoreInt32(&mem.prioritized, 1)
+	}
+	return hints
+}
+
+// isFull returns true if there is no room for a tx of the given size
+// without evicting other txs.
+func (mem *Mempool) isFull(txSize int) bool {
+	maxTxs, maxTxsBytes := mem.config.MaxTxs, mem.config.MaxTxsBytes
+	return (maxTxs > 0 && mem.Size() >= maxTxs) ||
+		(maxTxsBytes > 0 && mem.TxsBytes()+int64(txSize) > maxTxsBytes)
+}
+
+func (mem *Mempool) errMempoolIsFull() error {
+	return ErrMempoolIsFull{mem.Size(), mem.config.MaxTxs, mem.TxsBytes(), mem.config.MaxTxsBytes}
+}
+
+// makeRoomFor checks that a tx of the given size and hints can be added to
+// the mempool, evicting txs of a lower priority if the mempool is full.
+func (mem *Mempool) makeRoomFor(txSize int, hints TxHints) error {
+	for mem.isFull(txSize) {
+		lowest := mem.txsByPriority.Lowest()
+		if lowest == nil || lowest.Value.(*mempoolTx).priority >= hints.Priority {
+			return mem.errMempoolIsFull()
 		}
-		if mem.recheckCursor == nil {
-			// Done!
-			atomic.StoreInt32(&mem.rechecking, 0)
-			mem.logger.Info("Done rechecking txs")
+		memTx := lowest.Value.(*mempoolTx)
+		if !mem.removeTx(lowest) {
+			// the recheck just removed it
+			continue
 		}
-	default:
-		// ignore other messages
+		mem.logger.Info("Evicting tx for a higher priority tx", "priority", memTx.priority, "tx", memTx.tx)
+		// remove from cache (it might be good later)
+		mem.cache.Remove(memTx.tx)
+		types.FireEventEvictTx(mem.evsw, types.EventDataEvictTx{memTx.tx, "priority"})
 	}
+	return nil
 }
 
-// Get the valid transactions remaining
+// removeTx removes the element from the tx list and the priority index.
+// It returns false if the element was already removed,
+// since the recheck may remove txs without holding the lock.
+func (mem *Mempool) removeTx(e *clist.CElement) bool {
+	if !mem.txsByPriority.Remove(e) {
+		return false
+	}
+	mem.txs.Remove(e)
+	e.DetachPrev()
+	memTx := e.Value.(*mempoolTx)
+	atomic.AddInt64(&mem.txsBytes, -int64(len(memTx.tx)))
+	mem.writeWALRemoved(memTx.tx)
+	return true
+}
+
+// Get the valid transactions remaining, highest priority first.
 // If maxTxs is -1, there is no cap on returned transactions.
+// Txs still waiting to be rechecked are skipped, so a long recheck
+// doesn't hold up the next block.
 func (mem *Mempool) Reap(maxTxs int) types.Txs {
 	mem.proxyMtx.Lock()
 	defer mem.proxyMtx.Unlock()
 
-	for atomic.LoadInt32(&mem.rechecking) > 0 {
-		// TODO: Something better?
-		time.Sleep(time.Millisecond * 10)
-	}
-
 	txs := mem.collectTxs(maxTxs)
 	return txs
 }
 
+// ReapMaxBytesMaxGas returns txs, highest priority first, that fit in maxBytes
+// when encoded in a block and whose gas wanted, from the TxHints, fits in maxGas.
+// A negative maxBytes or maxGas means no limit.
+// Blocks on the lock, and skips txs waiting to be rechecked, like Reap().
+func (mem *Mempool) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
+	mem.proxyMtx.Lock()
+	defer mem.proxyMtx.Unlock()
+
+	return mem.txsByPriority.TxsMaxBytesMaxGas(maxBytes, maxGas)
+}
+
+// TxsPage returns up to limit txs, highest priority first, skipping the first
+// start ones. A negative limit means no limit. Unlike Reap(), it doesn't take
+// the lock, and includes the txs waiting to be rechecked.
+func (mem *Mempool) TxsPage(start, limit int) types.Txs {
+	return mem.txsByPriority.Page(start, limit)
+}
+
 // maxTxs: -1 means uncapped, 0 means none
 func (mem *Mempool) collectTxs(maxTxs int) types.Txs {
 	if maxTxs == 0 {
 		return []types.Tx{}
-	} else if maxTxs < 0 {
-		maxTxs = mem.txs.Len()
-	}
-	txs := make([]types.Tx, 0, cmn.MinInt(mem.txs.Len(), maxTxs))
-	for e := mem.txs.Front(); e != nil && len(txs) < maxTxs; e = e.Next() {
-		memTx := e.Value.(*mempoolTx)
-		txs = append(txs, memTx.tx)
 	}
-	return txs
+	return mem.txsByPriority.Txs(maxTxs)
 }
 
 // Tell mempool that these txs were committed.
-// Mempool will discard these txs.
+// Mempool will discard these txs, and evict txs that are past their TTL.
+// The txs left are rechecked in the background; if the commitLog,
+// from the app's Commit response, has RecheckHints, only the ones that
+// depend on the touched keys are (see recheck.go).
+// Since the caller holds the lock, like Reap() does, txs are never evicted
+// while a proposal block is being made.
 // NOTE: this should be called *after* block is committed by consensus.
 // NOTE: unsafe; Lock/Unlock must be managed by caller
-func (mem *Mempool) Update(height int, txs types.Txs) {
+func (mem *Mempool) Update(height int, txs types.Txs, commitLog string) {
 	// TODO: check err ?
 	mem.proxyAppConn.FlushSync() // To flush async resCb calls e.g. from CheckTx
 
+	touched, err := parseRecheckHints(commitLog)
+	if err != nil {
+		mem.logger.Error("Malformed recheck hints in Commit response", "log", commitLog, "err", err)
+	}
+
 	// First, create a lookup map of txns in new txs.
 	txsMap := make(map[string]struct{})
 	for _, tx := range txs {
@@ -302,89 +473,145 @@ func (mem *Mempool) Update(height int, txs types.Txs) {
 
 	// Set height
 	mem.height = height
-	// Remove transactions that are already in txs.
+	// Remove transactions that are already in txs, or expired.
+	// This drops them from the wal too.
 	goodTxs := mem.filterTxs(txsMap)
 	// Recheck mempool txs if any txs were committed in the block
 	// NOTE/XXX: in some apps a tx could be invalidated due to EndBlock,
 	//	so we really still do need to recheck, but this is for debugging
 	if mem.config.Recheck && (mem.config.RecheckEmpty || len(txs) > 0) {
-		mem.logger.Info("Recheck txs", "numtxs", len(goodTxs))
-		mem.recheckTxs(goodTxs)
-		// At this point, mem.txs are being rechecked.
-		// mem.recheckCursor re-scans mem.txs and possibly removes some txs.
-		// Before mem.Reap(), we should wait for mem.recheckCursor to be nil.
+		recheckTxs := touchedTxs(goodTxs, touched)
+		mem.logger.Info("Recheck txs", "numtxs", len(recheckTxs), "of", len(goodTxs))
+		mem.recheckTxs(recheckTxs)
+		// At this point, the txs are being rechecked in the background,
+		// which possibly removes some of them.
+		// mem.Reap() skips them until they're rechecked.
 	}
 }
 
-func (mem *Mempool) filterTxs(blockTxsMap map[string]struct{}) []types.Tx {
-	goodTxs := make([]types.Tx, 0, mem.txs.Len())
+func (mem *Mempool) filterTxs(blockTxsMap map[string]struct{}) []*clist.CElement {
+	now := time.Now()
+	goodTxs := make([]*clist.CElement, 0, mem.txs.Len())
 	for e := mem.txs.Front(); e != nil; e = e.Next() {
 		memTx := e.Value.(*mempoolTx)
 		// Remove the tx if it's alredy in a block.
 		if _, ok := blockTxsMap[string(memTx.tx)]; ok {
-			// remove from clist
-			mem.txs.Remove(e)
-			e.DetachPrev()
+			// remove from clist and priority index
+			if !mem.removeTx(e) {
+				continue
+			}
 
 			// NOTE: we don't remove committed txs from the cache.
+			types.FireEventIncludeTx(mem.evsw, types.EventDataIncludeTx{memTx.tx, mem.height})
+			continue
+		}
+		// Evict the tx if it has been waiting too long.
+		if mem.isExpired(memTx, now) {
+			if !mem.removeTx(e) {
+				continue
+			}
+			mem.logger.Info("Evicting expired tx", "height", memTx.Height(), "time", memTx.timestamp, "tx", memTx.tx)
+
+			// remove from cache (it might be good later)
+			mem.cache.Remove(memTx.tx)
+			types.FireEventEvictTx(mem.evsw, types.EventDataEvictTx{memTx.tx, "expired"})
 			continue
 		}
 		// Good tx!
-		goodTxs = append(goodTxs, memTx.tx)
+		goodTxs = append(goodTxs, e)
 	}
 	return goodTxs
 }
 
-// NOTE: pass in goodTxs because mem.txs can mutate concurrently.
-func (mem *Mempool) recheckTxs(goodTxs []types.Tx) {
-	if len(goodTxs) == 0 {
-		return
+// isExpired returns true if the tx has been in the mempool for longer than
+// ttl_num_blocks blocks or ttl_duration.
+func (mem *Mempool) isExpired(memTx *mempoolTx, now time.Time) bool {
+	if ttl := mem.config.TTLNumBlocks; ttl > 0 && mem.height-memTx.Height() > ttl {
+		return true
 	}
-	atomic.StoreInt32(&mem.rechecking, 1)
-	mem.recheckCursor = mem.txs.Front()
-	mem.recheckEnd = mem.txs.Back()
-
-	// Push txs to proxyAppConn
-	// NOTE: resCb() may be called concurrently.
-	for _, tx := range goodTxs {
-		mem.proxyAppConn.CheckTxAsync(tx)
+	if ttl := mem.config.TTL(); ttl > 0 && now.Sub(memTx.timestamp) > ttl {
+		return true
 	}
-	mem.proxyAppConn.FlushAsync()
+	return false
 }
 
 //--------------------------------------------------------------------------------
 
 // A transaction that successfully ran
 type mempoolTx struct {
-	counter int64    // a simple incrementing counter
-	height  int64    // height that this tx had been validated in
-	tx      types.Tx //
+	counter   int64       // a simple incrementing counter
+	height    int64       // height that this tx had been validated in
+	timestamp time.Time   // time that this tx arrived
+	tx        types.Tx    //
+	priority  int64       // from the app's TxHints, guarded by the priority index
+	sender    string      // from the app's TxHints
+	keys      []string    // from the app's TxHints
+	gasWanted int64       // from the app's TxHints
+	checkTx   abci.Result // the CheckTx response when the tx arrived
+	peers     *cmn.CMap   // keys of the peers that sent us the tx
+
+	rechecking int32 // number of rechecks the tx is waiting on, see recheck.go
 }
 
 func (memTx *mempoolTx) Height() int {
 	return int(atomic.LoadInt64(&memTx.height))
 }
 
+// isRechecking returns true if the tx is waiting to be rechecked.
+func (memTx *mempoolTx) isRechecking() bool {
+	return atomic.LoadInt32(&memTx.rechecking) > 0
+}
+
+func (memTx *mempoolTx) addPeer(peerKey string) {
+	memTx.peers.Set(peerKey, struct{}{})
+}
+
+// dependsOn returns true if the tx has to be rechecked after a block that
+// touched the keys: if they include its sender or one of its keys,
+// or if the app did not say what the tx depends on.
+func (memTx *mempoolTx) dependsOn(touched map[string]struct{}) bool {
+	if memTx.sender == "" && len(memTx.keys) == 0 {
+		return true
+	}
+	if _, ok := touched[memTx.sender]; ok && memTx.sender != "" {
+		return true
+	}
+	for _, key := range memTx.keys {
+		if _, ok := touched[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fromPeer returns true if the peer sent us the tx.
+func (memTx *mempoolTx) fromPeer(peerKey string) bool {
+	return memTx.peers.Has(peerKey)
+}
+
 //--------------------------------------------------------------------------------
 
 type txCache struct {
-	mtx  sync.Mutex
-	size int
-	map_ map[string]struct{}
-	list *list.List // to remove oldest tx when cache gets too big
+	mtx    sync.Mutex
+	size   int
+	map_   map[string]struct{}
+	hashes map[string]struct{} // hashes of the txs in map_, for announced txs
+	list   *list.List          // to remove oldest tx when cache gets too big
 }
 
 func newTxCache(cacheSize int) *txCache {
 	return &txCache{
-		size: cacheSize,
-		map_: make(map[string]struct{}, cacheSize),
-		list: list.New(),
+		size:   cacheSize,
+		map_:   make(map[string]struct{}, cacheSize),
+		hashes: make(map[string]struct{}, cacheSize),
+		list:   list.New(),
 	}
 }
 
 func (cache *txCache) Reset() {
 	cache.mtx.Lock()
 	cache.map_ = make(map[string]struct{}, cacheSize)
+	cache.hashes = make(map[string]struct{}, cacheSize)
 	cache.list.Init()
 	cache.mtx.Unlock()
 }
@@ -396,6 +623,14 @@ func (cache *txCache) Exists(tx types.Tx) bool {
 	return exists
 }
 
+// ExistsHash returns true if the tx with the given hash is in the cache.
+func (cache *txCache) ExistsHash(hash []byte) bool {
+	cache.mtx.Lock()
+	_, exists := cache.hashes[string(hash)]
+	cache.mtx.Unlock()
+	return exists
+}
+
 // Returns false if tx is in cache.
 func (cache *txCache) Push(tx types.Tx) bool {
 	cache.mtx.Lock()
@@ -411,9 +646,11 @@ func (cache *txCache) Push(tx types.Tx) bool {
 		// NOTE: the tx may have already been removed from the map
 		// but deleting a non-existent element is fine
 		delete(cache.map_, string(poppedTx))
+		delete(cache.hashes, string(poppedTx.Hash()))
 		cache.list.Remove(popped)
 	}
 	cache.map_[string(tx)] = struct{}{}
+	cache.hashes[string(tx.Hash())] = struct{}{}
 	cache.list.PushBack(tx)
 	return true
 }
@@ -421,5 +658,6 @@ func (cache *txCache) Push(tx types.Tx) bool {
 func (cache *txCache) Remove(tx types.Tx) {
 	cache.mtx.Lock()
 	delete(cache.map_, string(tx))
+	delete(cache.hashes, string(tx.Hash()))
 	cache.mtx.Unlock()
 }
@@ -2,12 +2,20 @@ package mempool
 
 import (
 	"encoding/binary"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	"github.com/tendermint/abci/example/counter"
+	abci "github.com/tendermint/abci/types"
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/proxy"
 	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/clist"
+	cmn "github.com/tendermint/tmlibs/common"
 	"github.com/tendermint/tmlibs/log"
 )
 
@@ -27,7 +35,7 @@ func TestSerialReap(t *testing.T) {
 	if _, err := appConnCon.Start(); err != nil {
 		t.Fatalf("Error starting ABCI client: %v", err.Error())
 	}
-	mempool := NewMempool(config.Mempool, appConnMem)
+	mempool := NewMempool(config.Mempool, appConnMem, 0)
 	mempool.SetLogger(log.TestingLogger())
 
 	deliverTxsRange := func(start, end int) {
@@ -67,7 +75,8 @@ func TestSerialReap(t *testing.T) {
 			binary.BigEndian.PutUint64(txBytes, uint64(i))
 			txs = append(txs, txBytes)
 		}
-		mempool.Update(0, txs)
+		mempool.Update(0, txs, "")
+		mempool.waitForRecheck()
 	}
 
 	commitRange := func(start, end int) {
@@ -121,3 +130,439 @@ func TestSerialReap(t *testing.T) {
 	// We should have 600 now.
 	reapCheck(600)
 }
+
+// priorityApp accepts every tx, with the first byte as its priority,
+// the second byte, if any, as its sender and the third, if any, as its gas wanted.
+type priorityApp struct {
+	abci.BaseApplication
+}
+
+func (app *priorityApp) CheckTx(tx []byte) abci.Result {
+	hints := TxHints{Priority: int64(tx[0])}
+	if len(tx) > 1 {
+		hints.Sender = string(tx[1:2])
+	}
+	if len(tx) > 2 {
+		hints.GasWanted = int64(tx[2])
+	}
+	return abci.NewResultOK(hints.Bytes(), "")
+}
+
+func newTestMempool(t *testing.T, app abci.Application, configure func(*cfg.MempoolConfig)) *Mempool {
+	config := cfg.ResetTestRoot("mempool_test")
+	configure(config.Mempool)
+
+	cc := proxy.NewLocalClientCreator(app)
+	appConnMem, _ := cc.NewABCIClient()
+	if _, err := appConnMem.Start(); err != nil {
+		t.Fatalf("Error starting ABCI client: %v", err.Error())
+	}
+	mempool := NewMempool(config.Mempool, appConnMem, 0)
+	mempool.SetLogger(log.TestingLogger())
+	return mempool
+}
+
+func newPriorityMempool(t *testing.T, maxTxs int) *Mempool {
+	return newTestMempool(t, &priorityApp{}, func(config *cfg.MempoolConfig) {
+		config.MaxTxs = maxTxs
+	})
+}
+
+func TestReapPriority(t *testing.T) {
+	mempool := newPriorityMempool(t, 0)
+	for _, tx := range []types.Tx{{1}, {5}, {3}, {5, 'b'}, {2}} {
+		if err := mempool.CheckTx(tx, nil); err != nil {
+			t.Fatalf("Error after CheckTx: %v", err)
+		}
+	}
+
+	// highest priority first, in arrival order for the same priority
+	assert.Equal(t, types.Txs{{5}, {5, 'b'}, {3}, {2}, {1}}, mempool.Reap(-1))
+	assert.Equal(t, types.Txs{{5}, {5, 'b'}}, mempool.Reap(2))
+
+	// the gossip list is still in arrival order
+	assert.Equal(t, types.Tx{1}, mempool.TxsFrontWait().Value.(*mempoolTx).tx)
+
+	// committed txs leave the index too
+	mempool.Update(1, types.Txs{{5}, {2}}, "")
+	mempool.waitForRecheck()
+	assert.Equal(t, types.Txs{{5, 'b'}, {3}, {1}}, mempool.Reap(-1))
+}
+
+func TestReapSkipsTxsBeingRechecked(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newPriorityMempool(t, 0)
+	txs := types.Txs{{3}, {2}, {1}}
+	for _, tx := range txs {
+		assert.Nil(mempool.CheckTx(tx, nil))
+	}
+
+	// as if the recheck hasn't got to {2} yet
+	memTx := mempool.txsByPriority.Get(txs[1]).Value.(*mempoolTx)
+	atomic.AddInt32(&memTx.rechecking, 1)
+	assert.Equal(types.Txs{{3}, {1}}, mempool.Reap(-1))
+	assert.Equal(types.Txs{{3}, {1}}, mempool.Reap(2))
+	assert.Equal(types.Txs{{3}, {1}}, mempool.ReapMaxBytesMaxGas(-1, -1))
+
+	// once it's rechecked, it's back
+	atomic.AddInt32(&memTx.rechecking, -1)
+	assert.Equal(txs, mempool.Reap(-1))
+}
+
+func TestReapMaxBytesMaxGas(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newPriorityMempool(t, 0)
+	txs := types.Txs{{3, 'a', 10}, {2, 'b', 5}, {1, 'c', 1}}
+	for _, tx := range txs {
+		assert.Nil(mempool.CheckTx(tx, nil))
+	}
+	txSize := int64(txs[0].WireSize())
+
+	assert.Equal(txs, mempool.ReapMaxBytesMaxGas(-1, -1))
+	assert.Empty(mempool.ReapMaxBytesMaxGas(0, -1))
+	assert.Equal(txs[:2], mempool.ReapMaxBytesMaxGas(2*txSize, -1))
+	assert.Equal(txs[:2], mempool.ReapMaxBytesMaxGas(3*txSize-1, -1))
+
+	// txs that don't fit are skipped for ones of a lower priority that do
+	assert.Equal(txs[1:], mempool.ReapMaxBytesMaxGas(-1, 6))
+	assert.Equal(types.Txs{txs[0], txs[2]}, mempool.ReapMaxBytesMaxGas(-1, 11))
+	assert.Equal(types.Txs{txs[2]}, mempool.ReapMaxBytesMaxGas(txSize, 1))
+}
+
+func TestEvictLowestPriority(t *testing.T) {
+	mempool := newPriorityMempool(t, 3)
+	for _, tx := range []types.Tx{{2}, {4}, {3}} {
+		mempool.CheckTx(tx, nil)
+	}
+
+	// a lower priority tx is dropped when the mempool is full
+	mempool.CheckTx(types.Tx{1}, nil)
+	assert.Equal(t, types.Txs{{4}, {3}, {2}}, mempool.Reap(-1))
+
+	// and a higher priority one evicts the lowest
+	mempool.CheckTx(types.Tx{5}, nil)
+	assert.Equal(t, types.Txs{{5}, {4}, {3}}, mempool.Reap(-1))
+	assert.Equal(t, 3, mempool.Size())
+
+	// the evicted tx can be resubmitted once there is room
+	mempool.Update(1, types.Txs{{5}}, "")
+	mempool.waitForRecheck()
+	mempool.CheckTx(types.Tx{2}, nil)
+	assert.Equal(t, types.Txs{{4}, {3}, {2}}, mempool.Reap(-1))
+}
+
+func TestManyTxsPerSender(t *testing.T) {
+	// like sequential nonces of an account
+	mempool := newPriorityMempool(t, 0)
+	mempool.CheckTx(types.Tx{1, 'a'}, nil)
+	mempool.CheckTx(types.Tx{2, 'a'}, nil)
+	mempool.CheckTx(types.Tx{3, 'b'}, nil)
+	assert.Equal(t, types.Txs{{3, 'b'}, {2, 'a'}, {1, 'a'}}, mempool.Reap(-1))
+}
+
+func TestMempoolLimits(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newTestMempool(t, abci.NewBaseApplication(), func(config *cfg.MempoolConfig) {
+		config.MaxTxs = 3
+		config.MaxTxsBytes = 10
+		config.MaxTxBytes = 5
+	})
+
+	assert.Equal(ErrTxTooLarge{5, 6}, mempool.CheckTx(types.Tx("123456"), nil))
+
+	assert.Nil(mempool.CheckTx(types.Tx("12345"), nil))
+	assert.Nil(mempool.CheckTx(types.Tx("1234"), nil))
+	assert.EqualValues(9, mempool.TxsBytes())
+
+	// over max_txs_bytes
+	assert.Equal(ErrMempoolIsFull{2, 3, 9, 10}, mempool.CheckTx(types.Tx("12"), nil))
+
+	// over max_txs
+	assert.Nil(mempool.CheckTx(types.Tx("1"), nil))
+	assert.Equal(ErrMempoolIsFull{3, 3, 10, 10}, mempool.CheckTx(types.Tx("2"), nil))
+
+	// a tx it already has is a duplicate, not over the limits
+	var res *abci.ResponseCheckTx
+	assert.Nil(mempool.CheckTx(types.Tx("1"), func(r *abci.Response) {
+		res = r.GetCheckTx()
+	}))
+	if assert.NotNil(res) {
+		assert.Equal(duplicateTxLog, res.Log)
+	}
+
+	mempool.Update(1, types.Txs{types.Tx("12345")}, "")
+	assert.Equal(2, mempool.Size())
+	assert.EqualValues(5, mempool.TxsBytes())
+	assert.Nil(mempool.CheckTx(types.Tx("2"), nil))
+}
+
+func TestMempoolDuplicateAfterCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newTestMempool(t, abci.NewBaseApplication(), func(config *cfg.MempoolConfig) {})
+
+	tx := types.Tx("tx")
+	assert.Nil(mempool.CheckTx(tx, nil))
+
+	// the cache forgets the tx, but the mempool still has it
+	mempool.cache.Reset()
+	var res *abci.ResponseCheckTx
+	assert.Nil(mempool.CheckTxFromPeer(tx, "peer", func(r *abci.Response) {
+		res = r.GetCheckTx()
+	}))
+	if assert.NotNil(res) {
+		assert.Equal(duplicateTxLog, res.Log)
+	}
+	assert.Equal(1, mempool.Size())
+	assert.True(mempool.txsByPriority.Get(tx).Value.(*mempoolTx).fromPeer("peer"))
+
+	// once committed, it's gone for good
+	mempool.Update(1, types.Txs{tx}, "")
+	assert.Equal(0, mempool.Size())
+	assert.Equal(0, len(mempool.Reap(-1)))
+
+	// the index removes the element it's given, even if another one has the same tx
+	idx, txs := newTxPriorityIndex(), clist.New()
+	e1 := txs.PushBack(&mempoolTx{counter: 1, tx: tx})
+	e2 := txs.PushBack(&mempoolTx{counter: 2, tx: tx})
+	idx.Add(e1)
+	idx.Add(e2)
+	assert.True(idx.Remove(e1))
+	assert.False(idx.Contains(e1))
+	assert.True(idx.Contains(e2))
+	assert.True(idx.Remove(e2))
+	assert.Nil(idx.Lowest())
+}
+
+func TestMempoolIsFullAfterCheckTx(t *testing.T) {
+	mempool := newPriorityMempool(t, 2)
+	mempool.CheckTx(types.Tx{2}, nil)
+	mempool.CheckTx(types.Tx{3}, nil)
+
+	// the mempool can't tell whether a tx can evict another before CheckTx,
+	// so the rejection comes in the response
+	var res *abci.ResponseCheckTx
+	err := mempool.CheckTx(types.Tx{1}, func(r *abci.Response) {
+		res = r.GetCheckTx()
+	})
+	assert.Nil(t, err)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, abci.CodeType_InternalError, res.Code)
+		assert.Equal(t, ErrMempoolIsFull{2, 2, 2, 1024 * 1024 * 1024}.Error(), res.Log)
+	}
+}
+
+func TestMempoolTTL(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newTestMempool(t, abci.NewBaseApplication(), func(config *cfg.MempoolConfig) {
+		config.TTLNumBlocks = 2
+		config.TTLDuration = 500
+	})
+	evsw := types.NewEventSwitch()
+	_, err := evsw.Start()
+	assert.Nil(err)
+	defer evsw.Stop()
+	mempool.SetEventSwitch(evsw)
+	var evicted types.Txs
+	types.AddListenerForEvent(evsw, "mempool_test", types.EventStringEvictTx(), func(data types.TMEventData) {
+		evicted = append(evicted, data.Unwrap().(types.EventDataEvictTx).Tx)
+	})
+
+	// expire by height
+	mempool.CheckTx(types.Tx("a"), nil)
+	mempool.Update(1, nil, "")
+	mempool.CheckTx(types.Tx("b"), nil)
+	mempool.Update(2, nil, "")
+	mempool.waitForRecheck()
+	assert.Equal(types.Txs{types.Tx("a"), types.Tx("b")}, mempool.Reap(-1))
+	mempool.Update(3, nil, "")
+	mempool.waitForRecheck()
+	assert.Equal(types.Txs{types.Tx("b")}, mempool.Reap(-1))
+	assert.Equal(types.Txs{types.Tx("a")}, evicted)
+
+	// an evicted tx is out of the cache, so it can be resubmitted
+	assert.Nil(mempool.CheckTx(types.Tx("a"), nil))
+	assert.Equal(2, mempool.Size())
+
+	// expire by time
+	time.Sleep(600 * time.Millisecond)
+	mempool.Update(4, nil, "")
+	assert.Equal(0, mempool.Size())
+}
+
+func TestMempoolWALReplay(t *testing.T) {
+	assert := assert.New(t)
+	config := cfg.ResetTestRoot("mempool_test")
+
+	newMempool := func() *Mempool {
+		appConnMem, _ := proxy.NewLocalClientCreator(abci.NewBaseApplication()).NewABCIClient()
+		if _, err := appConnMem.Start(); err != nil {
+			t.Fatalf("Error starting ABCI client: %v", err.Error())
+		}
+		mempool := NewMempool(config.Mempool, appConnMem, 0)
+		mempool.SetLogger(log.TestingLogger())
+		return mempool
+	}
+
+	mempool := newMempool()
+	for _, tx := range []string{"a", "b", "c"} {
+		assert.Nil(mempool.CheckTx(types.Tx(tx), nil))
+	}
+	// the committed and removed txs are dropped from the wal,
+	// without rewriting it
+	mempool.Update(1, types.Txs{types.Tx("b")}, "")
+	assert.True(mempool.RemoveTx(types.Tx("c").Hash()))
+	size, err := mempool.wal.Size()
+	assert.Nil(err)
+	assert.True(size > mempool.walCompactSize)
+
+	// a removed tx can come back
+	assert.Nil(mempool.CheckTx(types.Tx("d"), nil))
+	assert.True(mempool.RemoveTx(types.Tx("d").Hash()))
+	mempool.cache.Reset()
+	assert.Nil(mempool.CheckTx(types.Tx("d"), nil))
+	mempool.proxyAppConn.FlushSync()
+
+	restarted := newMempool()
+	assert.Nil(restarted.ReplayWAL())
+	assert.Equal(types.Txs{types.Tx("a"), types.Tx("d")}, restarted.Reap(-1))
+
+	// the wal was compacted on replay, not appended to
+	txs, err := readWAL(restarted.walPath())
+	assert.Nil(err)
+	assert.Equal([]types.Tx{types.Tx("a"), types.Tx("d")}, txs)
+	size, err = restarted.wal.Size()
+	assert.Nil(err)
+	assert.Equal(restarted.walCompactSize, size)
+}
+
+func TestMempoolTxInfoAndRemoveTx(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newPriorityMempool(t, 0)
+	tx := types.Tx{7, 'a', 3}
+	before := time.Now()
+	assert.Nil(mempool.CheckTx(tx, nil))
+
+	info := mempool.TxInfo(tx.Hash())
+	if assert.NotNil(info) {
+		assert.Equal(tx, info.Tx)
+		assert.Equal(abci.CodeType_OK, info.CheckTx.Code)
+		assert.Equal(int64(7), info.Priority)
+		assert.Equal(int64(3), info.GasWanted)
+		assert.False(info.Time.Before(before))
+	}
+	assert.Nil(mempool.TxInfo(types.Tx("nope").Hash()))
+
+	assert.True(mempool.RemoveTx(tx.Hash()))
+	assert.False(mempool.RemoveTx(tx.Hash()))
+	assert.Nil(mempool.TxInfo(tx.Hash()))
+	assert.Equal(0, mempool.Size())
+}
+
+func TestMempoolEvents(t *testing.T) {
+	assert := assert.New(t)
+	mempool := newTestMempool(t, &priorityApp{}, func(config *cfg.MempoolConfig) {
+		config.MaxTxs = 2
+	})
+	evsw := types.NewEventSwitch()
+	_, err := evsw.Start()
+	assert.Nil(err)
+	defer evsw.Stop()
+	mempool.SetEventSwitch(evsw)
+
+	var events []string
+	listen := func(event string) {
+		types.AddListenerForEvent(evsw, "mempool_test", event, func(data types.TMEventData) {
+			var tx types.Tx
+			switch data := data.Unwrap().(type) {
+			case types.EventDataAddTx:
+				tx = data.Tx
+			case types.EventDataRejectTx:
+				tx = data.Tx
+			case types.EventDataEvictTx:
+				tx = data.Tx
+			case types.EventDataIncludeTx:
+				tx = data.Tx
+			}
+			events = append(events, cmn.Fmt("%s %X", event, tx))
+		})
+	}
+	listen(types.EventStringAddTx())
+	listen(types.EventStringRejectTx())
+	listen(types.EventStringEvictTx())
+	listen(types.EventStringIncludeTx())
+
+	mempool.CheckTx(types.Tx{2}, nil)
+	mempool.CheckTx(types.Tx{3}, nil)
+	mempool.CheckTx(types.Tx{1}, nil) // full, and the lowest priority
+	mempool.CheckTx(types.Tx{4}, nil) // evicts {2}
+	mempool.Update(1, types.Txs{{3}}, "")
+	mempool.RemoveTx(types.Tx{4}.Hash())
+
+	assert.Equal([]string{
+		"AddTx 02",
+		"AddTx 03",
+		"RejectTx 01",
+		"EvictTx 02",
+		"AddTx 04",
+		"IncludeTx 03",
+		"EvictTx 04",
+	}, events)
+}
+
+// recheckApp accepts txs "sender/key", with the sender and the key in their
+// TxHints, and records the txs it rechecks.
+type recheckApp struct {
+	abci.BaseApplication
+
+	rechecking bool
+	rechecked  []string
+	invalid    map[string]bool // txs that fail their recheck
+}
+
+func (app *recheckApp) SetOption(key string, value string) string {
+	if key == RecheckOption {
+		app.rechecking = value == "on"
+	}
+	return ""
+}
+
+func (app *recheckApp) CheckTx(tx []byte) abci.Result {
+	if app.rechecking {
+		app.rechecked = append(app.rechecked, string(tx))
+		if app.invalid[string(tx)] {
+			return abci.NewError(abci.CodeType_BadNonce, "invalid after the block")
+		}
+	}
+	parts := strings.SplitN(string(tx), "/", 2)
+	hints := TxHints{Sender: parts[0]}
+	if len(parts) > 1 {
+		hints.Keys = []string{parts[1]}
+	}
+	return abci.NewResultOK(hints.Bytes(), "")
+}
+
+func TestRecheckTouchedTxs(t *testing.T) {
+	assert := assert.New(t)
+	app := &recheckApp{invalid: make(map[string]bool)}
+	mempool := newTestMempool(t, app, func(config *cfg.MempoolConfig) {
+		config.RecheckBatchSize = 2
+	})
+	for _, tx := range []string{"a", "b/x", "c/y", "d"} {
+		assert.Nil(mempool.CheckTx(types.Tx(tx), nil))
+	}
+	assert.Empty(app.rechecked)
+
+	// only the txs of the touched senders and keys are rechecked
+	app.invalid["d"] = true
+	mempool.Update(1, nil, RecheckHints{[]string{"a", "x"}}.Log())
+	mempool.waitForRecheck()
+	assert.Equal(4, len(mempool.Reap(-1)))
+	assert.Equal([]string{"a", "b/x"}, app.rechecked)
+
+	// without hints, every tx is, and the invalid ones are evicted
+	app.rechecked = nil
+	mempool.Update(2, nil, "")
+	mempool.waitForRecheck()
+	assert.Equal(types.Txs{types.Tx("a"), types.Tx("b/x"), types.Tx("c/y")}, mempool.Reap(-1))
+	assert.Equal([]string{"a", "b/x", "c/y", "d"}, app.rechecked)
+}
@@ -0,0 +1,139 @@
+package mempool
+
+import (
+	"sync"
+	"time"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/types"
+)
+
+/*
+
+The mempool reactor keeps a PeerState for each peer, in the peer's Data,
+with token buckets that limit the txs the peer can send.
+A peer can send peer_max_txs_rate txs and peer_max_bytes_rate bytes a second,
+in bursts of up to peerBurstSeconds' worth. Txs over the limits are dropped
+before CheckTx, so they don't take up the app's time.
+
+Each dropped tx, and each tx that fails CheckTx, is spam, and uses up one of
+the peer's peer_max_spam allowance, which refills over spamRefillPeriod.
+A peer that runs out is disconnected with ErrPeerSpam.
+
+*/
+
+const (
+	peerBurstSeconds = 10
+	spamRefillPeriod = time.Minute
+)
+
+// PeerState is what the mempool reactor knows about the txs a peer sent.
+type PeerState struct {
+	mtx     sync.Mutex
+	txs     *tokenBucket
+	bytes   *tokenBucket
+	spam    *tokenBucket
+	spammer bool
+	stats   types.MempoolPeerStats
+}
+
+func newPeerState(config *cfg.MempoolConfig, now time.Time) *PeerState {
+	maxSpam := float64(config.PeerMaxSpam)
+	return &PeerState{
+		txs:   newTokenBucket(float64(config.PeerMaxTxsRate), peerBurstSeconds*float64(config.PeerMaxTxsRate), now),
+		bytes: newTokenBucket(float64(config.PeerMaxBytesRate), peerBurstSeconds*float64(config.PeerMaxBytesRate), now),
+		spam:  newTokenBucket(maxSpam/spamRefillPeriod.Seconds(), maxSpam, now),
+	}
+}
+
+// Stats returns the counts of the txs the peer sent.
+func (ps *PeerState) Stats() types.MempoolPeerStats {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+	return ps.stats
+}
+
+// allowTx counts a tx of the given size from the peer.
+// It returns false if the tx is over the peer's rate limits, and should be dropped.
+func (ps *PeerState) allowTx(size int, now time.Time) bool {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	ps.stats.Txs++
+	ps.stats.Bytes += int64(size)
+	if !ps.txs.has(1, now) || !ps.bytes.has(float64(size), now) {
+		ps.stats.Throttled++
+		ps.addSpam(now)
+		return false
+	}
+	ps.txs.take(1)
+	ps.bytes.take(float64(size))
+	return true
+}
+
+// invalidTx counts a tx from the peer that failed CheckTx.
+func (ps *PeerState) invalidTx(now time.Time) {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	ps.stats.Invalid++
+	ps.addSpam(now)
+}
+
+func (ps *PeerState) addSpam(now time.Time) {
+	if !ps.spam.has(1, now) {
+		ps.spammer = true
+		return
+	}
+	ps.spam.take(1)
+}
+
+// isSpammer returns true once the peer has run out of spam allowance.
+func (ps *PeerState) isSpammer() bool {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+	return ps.spammer
+}
+
+//--------------------------------------------------------------------------------
+
+// tokenBucket holds up to capacity tokens, and gets rate more each second.
+// A rate of 0 means no limit.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket returns a full bucket.
+func newTokenBucket(rate, capacity float64, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// has refills the bucket, and returns true if it holds n tokens.
+func (b *tokenBucket) has(n float64, now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	return b.tokens >= n
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate <= 0 {
+		return
+	}
+	b.tokens -= n
+}
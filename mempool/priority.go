@@ -0,0 +1,263 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/tendermint/tmlibs/clist"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+/*
+
+The ABCI CheckTx response only has a Code, Data and Log,
+so an app passes hints for the mempool in the Data of an OK response:
+TxHintsPrefix followed by the JSON encoded TxHints.
+Data without the prefix is left alone and the tx gets the zero TxHints.
+
+*/
+
+// TxHintsPrefix marks CheckTx response Data holding TxHints.
+const TxHintsPrefix = "mempool:"
+
+// TxHints is what the app can tell the mempool about a tx in its CheckTx response.
+type TxHints struct {
+	// Txs with a higher priority are reaped first and evicted last.
+	Priority int64 `json:"priority,omitempty"`
+
+	// The account the tx is from. See RecheckHints.
+	Sender string `json:"sender,omitempty"`
+
+	// The gas the tx may use, counted against the max gas of a block.
+	GasWanted int64 `json:"gas_wanted,omitempty"`
+
+	// Other keys, besides the sender, whose changes can invalidate the tx.
+	// See RecheckHints.
+	Keys []string `json:"keys,omitempty"`
+}
+
+// Bytes returns the CheckTx response Data for the hints.
+func (h TxHints) Bytes() []byte {
+	bz, err := json.Marshal(h)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(TxHintsPrefix), bz...)
+}
+
+// ParseTxHints returns the hints in the CheckTx response Data,
+// or the zero TxHints if it has none.
+func ParseTxHints(data []byte) (TxHints, error) {
+	var hints TxHints
+	if !bytes.HasPrefix(data, []byte(TxHintsPrefix)) {
+		return hints, nil
+	}
+	err := json.Unmarshal(data[len(TxHintsPrefix):], &hints)
+	return hints, err
+}
+
+//--------------------------------------------------------------------------------
+
+// txPriorityIndex orders the elements of the mempool's tx list
+// by priority, highest first, and by arrival for txs of the same priority.
+// It also tracks the element of each tx, by the tx and by its hash.
+// The tx list itself stays in arrival order for gossip.
+type txPriorityIndex struct {
+	mtx    sync.Mutex
+	elems  []*clist.CElement
+	txs    map[string]*clist.CElement
+	hashes map[string]*clist.CElement
+}
+
+func newTxPriorityIndex() *txPriorityIndex {
+	return &txPriorityIndex{
+		txs:    make(map[string]*clist.CElement),
+		hashes: make(map[string]*clist.CElement),
+	}
+}
+
+// before returns true if a is reaped before b.
+func before(a, b *mempoolTx) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.counter < b.counter
+}
+
+// search returns the position of memTx, or where it would be inserted.
+func (idx *txPriorityIndex) search(memTx *mempoolTx) int {
+	return sort.Search(len(idx.elems), func(i int) bool {
+		return !before(idx.elems[i].Value.(*mempoolTx), memTx)
+	})
+}
+
+func (idx *txPriorityIndex) Add(e *clist.CElement) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	idx.add(e)
+}
+
+func (idx *txPriorityIndex) add(e *clist.CElement) {
+	memTx := e.Value.(*mempoolTx)
+	i := idx.search(memTx)
+	idx.elems = append(idx.elems, nil)
+	copy(idx.elems[i+1:], idx.elems[i:])
+	idx.elems[i] = e
+	idx.txs[string(memTx.tx)] = e
+	idx.hashes[string(memTx.tx.Hash())] = e
+}
+
+// Remove returns false if the element is not in the index.
+func (idx *txPriorityIndex) Remove(e *clist.CElement) bool {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if !idx.contains(e) {
+		return false
+	}
+	idx.remove(e)
+	return true
+}
+
+func (idx *txPriorityIndex) remove(e *clist.CElement) {
+	memTx := e.Value.(*mempoolTx)
+	if i, ok := idx.indexOf(e); ok {
+		idx.elems = append(idx.elems[:i], idx.elems[i+1:]...)
+	}
+	if idx.txs[string(memTx.tx)] == e {
+		delete(idx.txs, string(memTx.tx))
+		delete(idx.hashes, string(memTx.tx.Hash()))
+	}
+}
+
+// Contains returns true if the element is in the index.
+func (idx *txPriorityIndex) Contains(e *clist.CElement) bool {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	return idx.contains(e)
+}
+
+func (idx *txPriorityIndex) contains(e *clist.CElement) bool {
+	_, ok := idx.indexOf(e)
+	return ok
+}
+
+// indexOf returns the position of the element in elems, and false if it is not there.
+// The counter of each tx is unique, so this finds the element itself
+// and not another one for the same tx.
+func (idx *txPriorityIndex) indexOf(e *clist.CElement) (int, bool) {
+	i := idx.search(e.Value.(*mempoolTx))
+	return i, i < len(idx.elems) && idx.elems[i] == e
+}
+
+// UpdatePriority moves the element to its place for a new priority,
+// if it is still in the index.
+func (idx *txPriorityIndex) UpdatePriority(e *clist.CElement, priority int64) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	memTx := e.Value.(*mempoolTx)
+	if !idx.contains(e) || memTx.priority == priority {
+		return
+	}
+	idx.remove(e)
+	memTx.priority = priority
+	idx.add(e)
+}
+
+// Lowest returns the element that would be evicted first, or nil if there is none.
+func (idx *txPriorityIndex) Lowest() *clist.CElement {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if len(idx.elems) == 0 {
+		return nil
+	}
+	return idx.elems[len(idx.elems)-1]
+}
+
+// Get returns the element of the tx, or nil if it is not in the mempool.
+func (idx *txPriorityIndex) Get(tx types.Tx) *clist.CElement {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	return idx.txs[string(tx)]
+}
+
+// GetByHash returns the element of the tx with the given hash,
+// or nil if it is not in the mempool.
+func (idx *txPriorityIndex) GetByHash(hash []byte) *clist.CElement {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	return idx.hashes[string(hash)]
+}
+
+// Txs returns up to maxTxs txs, highest priority first,
+// skipping the ones waiting to be rechecked.
+func (idx *txPriorityIndex) Txs(maxTxs int) types.Txs {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	txs := make([]types.Tx, 0, len(idx.elems))
+	for _, e := range idx.elems {
+		if maxTxs >= 0 && len(txs) >= maxTxs {
+			break
+		}
+		memTx := e.Value.(*mempoolTx)
+		if memTx.isRechecking() {
+			continue
+		}
+		txs = append(txs, memTx.tx)
+	}
+	return txs
+}
+
+// Page returns up to limit txs, highest priority first, from the start'th.
+// A negative limit means no limit.
+func (idx *txPriorityIndex) Page(start, limit int) types.Txs {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	if start >= len(idx.elems) {
+		return types.Txs{}
+	}
+	end := len(idx.elems)
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	txs := make([]types.Tx, 0, end-start)
+	for _, e := range idx.elems[start:end] {
+		txs = append(txs, e.Value.(*mempoolTx).tx)
+	}
+	return txs
+}
+
+// TxsMaxBytesMaxGas returns txs, highest priority first, with a total
+// encoded size (see Tx.WireSize) of at most maxBytes and a total gas wanted
+// of at most maxGas. Txs that don't fit are skipped, so smaller txs of a lower
+// priority can fill up the rest, as are txs waiting to be rechecked.
+// A negative max means no limit.
+func (idx *txPriorityIndex) TxsMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	var txs []types.Tx
+	var totalBytes, totalGas int64
+	for _, e := range idx.elems {
+		memTx := e.Value.(*mempoolTx)
+		if memTx.isRechecking() {
+			continue
+		}
+		txBytes := int64(memTx.tx.WireSize())
+		if maxBytes >= 0 && totalBytes+txBytes > maxBytes {
+			continue
+		}
+		if maxGas >= 0 && totalGas+memTx.gasWanted > maxGas {
+			continue
+		}
+		totalBytes += txBytes
+		totalGas += memTx.gasWanted
+		txs = append(txs, memTx.tx)
+	}
+	return txs
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	abci "github.com/tendermint/abci/types"
@@ -20,20 +21,43 @@ const (
 
 	maxMempoolMessageSize      = 1048576 // 1MB TODO make it configurable
 	peerCatchupSleepIntervalMS = 100     // If peer is behind, sleep this amount
+	maxAnnounceHashes          = 1000    // Max number of tx hashes in a TxHashesMessage or TxRequestMessage
+
+	// Before asking another peer for a tx we've requested
+	txRequestTimeout = 10 * time.Second
+
+	// NodeInfoAnnounceTxs is in the NodeInfo.Other of nodes that understand
+	// TxHashesMessage and TxRequestMessage
+	NodeInfoAnnounceTxs = "mempool_announce_txs=on"
 )
 
+/*
+
+By default a node pushes every tx in its mempool to its peers.
+With announce_txs, a node sends peers that also set it TxHashesMessages instead,
+and the peer replies with a TxRequestMessage for the txs it has not seen,
+so each tx body crosses a link at most once. A tx announced by several peers
+is only requested from the first, unless it doesn't arrive within txRequestTimeout.
+Peers that don't have NodeInfoAnnounceTxs in their NodeInfo still get every tx pushed.
+
+*/
+
 // MempoolReactor handles mempool tx broadcasting amongst peers.
 type MempoolReactor struct {
 	p2p.BaseReactor
 	config  *cfg.MempoolConfig
 	Mempool *Mempool
 	evsw    types.EventSwitch
+
+	peerStateMtx sync.Mutex  // for creating peer states
+	txRequests   *txRequests // txs we've asked peers for
 }
 
 func NewMempoolReactor(config *cfg.MempoolConfig, mempool *Mempool) *MempoolReactor {
 	memR := &MempoolReactor{
-		config:  config,
-		Mempool: mempool,
+		config:     config,
+		Mempool:    mempool,
+		txRequests: newTxRequests(txRequestTimeout),
 	}
 	memR.BaseReactor = *p2p.NewBaseReactor("MempoolReactor", memR)
 	return memR
@@ -45,13 +69,19 @@ func (memR *MempoolReactor) GetChannels() []*p2p.ChannelDescriptor {
 		&p2p.ChannelDescriptor{
 			ID:       MempoolChannel,
 			Priority: 5,
+			SendRate: memR.config.GossipSendRate,
 		},
 	}
 }
 
 // Implements Reactor
 func (memR *MempoolReactor) AddPeer(peer *p2p.Peer) {
-	go memR.broadcastTxRoutine(peer)
+	memR.peerState(peer)
+	if memR.config.AnnounceTxs && announcesTxs(peer.NodeInfo) {
+		go memR.announceTxRoutine(peer.Key, peer)
+	} else {
+		go memR.broadcastTxRoutine(peer.Key, peer)
+	}
 }
 
 // Implements Reactor
@@ -70,20 +100,146 @@ func (memR *MempoolReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
 
 	switch msg := msg.(type) {
 	case *TxMessage:
-		err := memR.Mempool.CheckTx(msg.Tx, nil)
+		memR.txRequests.done(msg.Tx.Hash())
+		ps := memR.peerState(src)
+		if !ps.allowTx(len(msg.Tx), time.Now()) {
+			memR.Logger.Debug("Dropped tx over the peer's rate limits", "peer", src, "tx", msg.Tx)
+			memR.stopPeerIfSpammer(src, ps)
+			return
+		}
+		err := memR.Mempool.CheckTxFromPeer(msg.Tx, src.Key, func(res *abci.Response) {
+			if isInvalidTx(res) {
+				ps.invalidTx(time.Now())
+				memR.stopPeerIfSpammer(src, ps)
+			}
+		})
 		if err != nil {
 			// Bad, seen, or conflicting tx.
 			memR.Logger.Info("Could not add tx", "tx", msg.Tx)
+			if _, ok := err.(ErrTxTooLarge); ok {
+				ps.invalidTx(time.Now())
+				memR.stopPeerIfSpammer(src, ps)
+			}
 			return
 		} else {
 			memR.Logger.Info("Added valid tx", "tx", msg.Tx)
 		}
 		// broadcasting happens from go routines per peer
+	case *TxHashesMessage:
+		// ask for the txs we haven't seen, and haven't asked another peer for
+		var missing [][]byte
+		for i, hash := range msg.Hashes {
+			if i >= maxAnnounceHashes {
+				break
+			}
+			if !memR.Mempool.cache.ExistsHash(hash) {
+				missing = append(missing, hash)
+			}
+		}
+		missing = memR.txRequests.request(missing, time.Now())
+		if len(missing) > 0 {
+			src.TrySend(MempoolChannel, struct{ MempoolMessage }{&TxRequestMessage{missing}})
+		}
+	case *TxRequestMessage:
+		for i, hash := range msg.Hashes {
+			if i >= maxAnnounceHashes {
+				break
+			}
+			// the tx may have been committed or evicted since we announced it
+			tx := memR.Mempool.TxByHash(hash)
+			if tx == nil {
+				continue
+			}
+			// don't block the peer's receive routine; if the send queue is full,
+			// the peer asks someone else once its request times out
+			if !src.TrySend(MempoolChannel, struct{ MempoolMessage }{&TxMessage{Tx: tx}}) {
+				break
+			}
+		}
 	default:
 		memR.Logger.Error(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
 	}
 }
 
+// peerState returns the peer's state, creating it if there is none yet.
+// The switch starts the peer before calling AddPeer, so txs can arrive first.
+func (memR *MempoolReactor) peerState(peer *p2p.Peer) *PeerState {
+	memR.peerStateMtx.Lock()
+	defer memR.peerStateMtx.Unlock()
+	if ps, ok := peer.Data.Get(types.PeerMempoolStateKey).(*PeerState); ok {
+		return ps
+	}
+	ps := newPeerState(memR.config, time.Now())
+	peer.Data.Set(types.PeerMempoolStateKey, ps)
+	return ps
+}
+
+// txRequests keeps the hashes of the txs we've asked peers for,
+// so we don't ask for them again until they time out.
+type txRequests struct {
+	mtx       sync.Mutex
+	timeout   time.Duration
+	requested map[string]time.Time // tx hash -> when we asked for it
+}
+
+func newTxRequests(timeout time.Duration) *txRequests {
+	return &txRequests{
+		timeout:   timeout,
+		requested: make(map[string]time.Time),
+	}
+}
+
+// request returns the hashes that aren't already requested, or whose requests
+// timed out, and records them as requested now.
+func (r *txRequests) request(hashes [][]byte, now time.Time) [][]byte {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// forget the requests that timed out, so the map doesn't grow
+	// with txs that never arrived
+	if len(r.requested) > maxAnnounceHashes {
+		for hash, at := range r.requested {
+			if now.Sub(at) >= r.timeout {
+				delete(r.requested, hash)
+			}
+		}
+	}
+
+	var toRequest [][]byte
+	for _, hash := range hashes {
+		if at, ok := r.requested[string(hash)]; ok && now.Sub(at) < r.timeout {
+			continue
+		}
+		r.requested[string(hash)] = now
+		toRequest = append(toRequest, hash)
+	}
+	return toRequest
+}
+
+// done forgets the request for the tx, once it arrived.
+func (r *txRequests) done(hash []byte) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.requested, string(hash))
+}
+
+// isInvalidTx returns true if the tx failed the app's CheckTx. Txs that are
+// already in the cache, or that the mempool has no room for, are not invalid.
+func isInvalidTx(res *abci.Response) bool {
+	r, ok := res.Value.(*abci.Response_CheckTx)
+	if !ok || r.CheckTx.Code == abci.CodeType_OK {
+		return false
+	}
+	return r.CheckTx.Code != abci.CodeType_InternalError && r.CheckTx.Log != duplicateTxLog
+}
+
+// stopPeerIfSpammer disconnects the peer once it has run out of spam allowance.
+func (memR *MempoolReactor) stopPeerIfSpammer(peer *p2p.Peer, ps *PeerState) {
+	if ps.isSpammer() && peer.IsRunning() {
+		memR.Switch.StopPeerForError(peer, ErrPeerSpam{ps.Stats()})
+	}
+}
+
 // Just an alias for CheckTx since broadcasting happens in peer routines
 func (memR *MempoolReactor) BroadcastTx(tx types.Tx, cb func(*abci.Response)) error {
 	return memR.Mempool.CheckTx(tx, cb)
@@ -99,10 +255,10 @@ type Peer interface {
 	Get(string) interface{}
 }
 
-// Send new mempool txs to peer.
+// Send new mempool txs to peer, except the ones it sent us.
 // TODO: Handle mempool or reactor shutdown?
 // As is this routine may block forever if no new txs come in.
-func (memR *MempoolReactor) broadcastTxRoutine(peer Peer) {
+func (memR *MempoolReactor) broadcastTxRoutine(peerKey string, peer Peer) {
 	if !memR.config.Broadcast {
 		return
 	}
@@ -119,6 +275,11 @@ func (memR *MempoolReactor) broadcastTxRoutine(peer Peer) {
 			next = memR.Mempool.TxsFrontWait() // Wait until a tx is available
 		}
 		memTx := next.Value.(*mempoolTx)
+		// don't echo the tx back to the peers that sent it
+		if memTx.fromPeer(peerKey) {
+			next = next.NextWait()
+			continue
+		}
 		// make sure the peer is up to date
 		height := memTx.Height()
 		if peerState_i := peer.Get(types.PeerStateKey); peerState_i != nil {
@@ -141,16 +302,81 @@ func (memR *MempoolReactor) broadcastTxRoutine(peer Peer) {
 	}
 }
 
+// Send the hashes of new mempool txs to peer, except the ones it sent us,
+// in batches of the txs that are ready to send.
+// The peer asks for the txs it wants with a TxRequestMessage.
+func (memR *MempoolReactor) announceTxRoutine(peerKey string, peer Peer) {
+	if !memR.config.Broadcast {
+		return
+	}
+
+	var next *clist.CElement
+	for {
+		if !memR.IsRunning() || !peer.IsRunning() {
+			return // Quit!
+		}
+		if next == nil {
+			// See broadcastTxRoutine
+			next = memR.Mempool.TxsFrontWait() // Wait until a tx is available
+		}
+
+		var hashes [][]byte
+		last, height := next, 0
+		for e := next; e != nil && len(hashes) < maxAnnounceHashes; e = e.Next() {
+			last = e
+			memTx := e.Value.(*mempoolTx)
+			if memTx.fromPeer(peerKey) {
+				continue
+			}
+			hashes = append(hashes, memTx.tx.Hash())
+			height = memTx.Height()
+		}
+		// make sure the peer is up to date
+		if peerState_i := peer.Get(types.PeerStateKey); peerState_i != nil {
+			peerState := peerState_i.(PeerState)
+			if peerState.GetHeight() < height-1 { // Allow for a lag of 1 block
+				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+				continue
+			}
+		}
+		if len(hashes) > 0 {
+			success := peer.Send(MempoolChannel, struct{ MempoolMessage }{&TxHashesMessage{hashes}})
+			if !success {
+				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+				continue
+			}
+		}
+
+		next = last.NextWait()
+	}
+}
+
+// announcesTxs returns true if the node understands TxHashesMessage and TxRequestMessage.
+func announcesTxs(nodeInfo *p2p.NodeInfo) bool {
+	if nodeInfo == nil {
+		return false
+	}
+	for _, other := range nodeInfo.Other {
+		if other == NodeInfoAnnounceTxs {
+			return true
+		}
+	}
+	return false
+}
+
 // implements events.Eventable
 func (memR *MempoolReactor) SetEventSwitch(evsw types.EventSwitch) {
 	memR.evsw = evsw
+	memR.Mempool.SetEventSwitch(evsw)
 }
 
 //-----------------------------------------------------------------------------
 // Messages
 
 const (
-	msgTypeTx = byte(0x01)
+	msgTypeTx        = byte(0x01)
+	msgTypeTxHashes  = byte(0x02)
+	msgTypeTxRequest = byte(0x03)
 )
 
 type MempoolMessage interface{}
@@ -158,6 +384,8 @@ type MempoolMessage interface{}
 var _ = wire.RegisterInterface(
 	struct{ MempoolMessage }{},
 	wire.ConcreteType{&TxMessage{}, msgTypeTx},
+	wire.ConcreteType{&TxHashesMessage{}, msgTypeTxHashes},
+	wire.ConcreteType{&TxRequestMessage{}, msgTypeTxRequest},
 )
 
 func DecodeMessage(bz []byte) (msgType byte, msg MempoolMessage, err error) {
@@ -177,3 +405,25 @@ type TxMessage struct {
 func (m *TxMessage) String() string {
 	return fmt.Sprintf("[TxMessage %v]", m.Tx)
 }
+
+//-------------------------------------
+
+// TxHashesMessage announces the hashes of txs in the sender's mempool.
+type TxHashesMessage struct {
+	Hashes [][]byte
+}
+
+func (m *TxHashesMessage) String() string {
+	return fmt.Sprintf("[TxHashesMessage %d hashes]", len(m.Hashes))
+}
+
+//-------------------------------------
+
+// TxRequestMessage asks for the txs with the given hashes.
+type TxRequestMessage struct {
+	Hashes [][]byte
+}
+
+func (m *TxRequestMessage) String() string {
+	return fmt.Sprintf("[TxRequestMessage %d hashes]", len(m.Hashes))
+}
@@ -0,0 +1,274 @@
+package mempool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	abci "github.com/tendermint/abci/types"
+	wire "github.com/tendermint/go-wire"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/proxy"
+	"github.com/tendermint/tendermint/types"
+)
+
+// countingReactor counts the txs each peer sends it, and the messages of each type.
+type countingReactor struct {
+	*MempoolReactor
+
+	mtx      sync.Mutex
+	received map[string]int // peer key -> number of txs
+	msgs     map[byte]int   // msg type -> number of msgs
+}
+
+func (r *countingReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
+	r.mtx.Lock()
+	r.msgs[msgBytes[0]]++
+	if msgBytes[0] == msgTypeTx {
+		r.received[src.Key]++
+	}
+	r.mtx.Unlock()
+	r.MempoolReactor.Receive(chID, src, msgBytes)
+}
+
+func (r *countingReactor) receivedMsgs(msgType byte) int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.msgs[msgType]
+}
+
+func (r *countingReactor) receivedFrom(peerKey string) int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.received[peerKey]
+}
+
+// makeAndConnectMempoolReactors makes N connected mempool reactors.
+// configure, if not nil, can change the config of each.
+func makeAndConnectMempoolReactors(t *testing.T, N int, configure func(int, *cfg.MempoolConfig)) ([]*countingReactor, []*p2p.Switch) {
+	return makeMempoolReactors(t, N, configure, p2p.Connect2Switches)
+}
+
+// connectLine connects each switch to the next one only.
+func connectLine(switches []*p2p.Switch, i, j int) {
+	if j == i+1 {
+		p2p.Connect2Switches(switches, i, j)
+	}
+}
+
+// makeMempoolReactors makes N mempool reactors, and connects them with connect.
+func makeMempoolReactors(t *testing.T, N int, configure func(int, *cfg.MempoolConfig),
+	connect func([]*p2p.Switch, int, int)) ([]*countingReactor, []*p2p.Switch) {
+	config := cfg.ResetTestRoot("mempool_reactor_test")
+	reactors := make([]*countingReactor, N)
+	logger := log.TestingLogger()
+	for i := 0; i < N; i++ {
+		appConnMem, _ := proxy.NewLocalClientCreator(abci.NewBaseApplication()).NewABCIClient()
+		if _, err := appConnMem.Start(); err != nil {
+			t.Fatalf("Error starting ABCI client: %v", err.Error())
+		}
+		// each node gets its own wal
+		mempoolConfig := *config.Mempool
+		mempoolConfig.RootDir = cmn.Fmt("%s/node%d", config.RootDir, i)
+		if configure != nil {
+			configure(i, &mempoolConfig)
+		}
+		mempool := NewMempool(&mempoolConfig, appConnMem, 0)
+		mempool.SetLogger(logger.With("validator", i))
+
+		reactors[i] = &countingReactor{
+			MempoolReactor: NewMempoolReactor(&mempoolConfig, mempool),
+			received:       make(map[string]int),
+			msgs:           make(map[byte]int),
+		}
+		reactors[i].SetLogger(logger.With("validator", i))
+	}
+
+	switches := p2p.MakeConnectedSwitches(config.P2P, N, func(i int, s *p2p.Switch) *p2p.Switch {
+		s.AddReactor("MEMPOOL", reactors[i])
+		if reactors[i].config.AnnounceTxs {
+			s.NodeInfo().Other = append(s.NodeInfo().Other, NodeInfoAnnounceTxs)
+		}
+		return s
+	}, connect)
+	return reactors, switches
+}
+
+func TestReactorNoEchoes(t *testing.T) {
+	assert := assert.New(t)
+	const N, nTxs = 4, 10
+	// in a line, every tx has one way to go, so the counts are exact
+	reactors, switches := makeMempoolReactors(t, N, nil, connectLine)
+	defer stopSwitches(switches)
+
+	addTxsAndWait(t, reactors, nTxs)
+
+	keys := make([]string, N)
+	for i, s := range switches {
+		keys[i] = s.NodeInfo().PubKey.KeyString()
+	}
+	// each tx goes down every link once, and is never sent back up it
+	for i := 0; i+1 < N; i++ {
+		assert.Equal(nTxs, reactors[i+1].receivedFrom(keys[i]), "link %d->%d", i, i+1)
+		assert.Zero(reactors[i].receivedFrom(keys[i+1]), "link %d->%d", i+1, i)
+	}
+}
+
+func stopSwitches(switches []*p2p.Switch) {
+	for _, s := range switches {
+		s.Stop()
+	}
+}
+
+// addTxsAndWait adds nTxs txs to the first reactor's mempool
+// and waits for them to reach the others.
+func addTxsAndWait(t *testing.T, reactors []*countingReactor, nTxs int) {
+	for i := 0; i < nTxs; i++ {
+		assert.Nil(t, reactors[0].Mempool.CheckTx(types.Tx{byte(i)}, nil))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 1; i < len(reactors); i++ {
+		for reactors[i].Mempool.Size() < nTxs && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, nTxs, reactors[i].Mempool.Size(), "node %d is missing txs", i)
+	}
+	// give any extra copies time to arrive
+	time.Sleep(500 * time.Millisecond)
+}
+
+func TestReactorAnnounceTxs(t *testing.T) {
+	assert := assert.New(t)
+	const N, nTxs = 3, 10
+	reactors, switches := makeAndConnectMempoolReactors(t, N, func(i int, config *cfg.MempoolConfig) {
+		config.AnnounceTxs = true
+	})
+	defer stopSwitches(switches)
+
+	addTxsAndWait(t, reactors, nTxs)
+
+	// a node asks for each tx once, even when several peers announce it,
+	// and never gets back its own txs
+	for i := 1; i < N; i++ {
+		assert.NotZero(reactors[i].receivedMsgs(msgTypeTxHashes), "node %d", i)
+		assert.Equal(nTxs, reactors[i].receivedMsgs(msgTypeTx), "node %d", i)
+	}
+	assert.Zero(reactors[0].receivedMsgs(msgTypeTx))
+}
+
+func TestTxRequests(t *testing.T) {
+	assert := assert.New(t)
+	requests := newTxRequests(time.Second)
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	now := time.Now()
+
+	assert.Equal([][]byte{a, b}, requests.request([][]byte{a, b}, now))
+
+	// a tx in flight isn't asked for again
+	assert.Equal([][]byte{c}, requests.request([][]byte{a, b, c}, now))
+
+	// unless it arrived, or its request timed out
+	requests.done(a)
+	assert.Equal([][]byte{a}, requests.request([][]byte{a, c}, now))
+	assert.Equal([][]byte{b}, requests.request([][]byte{b}, now.Add(time.Second)))
+}
+
+func TestReactorAnnounceTxsFallback(t *testing.T) {
+	assert := assert.New(t)
+	// node 1 is an old node that only gets txs pushed
+	reactors, switches := makeAndConnectMempoolReactors(t, 2, func(i int, config *cfg.MempoolConfig) {
+		config.AnnounceTxs = i == 0
+	})
+	defer stopSwitches(switches)
+
+	addTxsAndWait(t, reactors, 5)
+
+	assert.Equal(5, reactors[1].receivedMsgs(msgTypeTx))
+	assert.Zero(reactors[1].receivedMsgs(msgTypeTxHashes))
+	assert.Zero(reactors[0].receivedMsgs(msgTypeTxRequest))
+}
+
+func TestPeerStateLimits(t *testing.T) {
+	assert := assert.New(t)
+	config := cfg.DefaultMempoolConfig()
+	config.PeerMaxTxsRate = 1
+	config.PeerMaxBytesRate = 100
+	config.PeerMaxSpam = 3
+	now := time.Now()
+	ps := newPeerState(config, now)
+
+	// a burst of peerBurstSeconds' worth of txs is allowed
+	for i := 0; i < peerBurstSeconds; i++ {
+		assert.True(ps.allowTx(1, now), "tx %d", i)
+	}
+	assert.False(ps.allowTx(1, now))
+	// and then one a second
+	now = now.Add(time.Second)
+	assert.True(ps.allowTx(1, now))
+	// as long as they fit in the bytes rate
+	now = now.Add(2 * time.Second)
+	assert.False(ps.allowTx(2000, now))
+	assert.True(ps.allowTx(10, now))
+	assert.Equal(types.MempoolPeerStats{Txs: 14, Bytes: 2022, Throttled: 2}, ps.Stats())
+	assert.False(ps.isSpammer())
+
+	// invalid txs use up the rest of the spam allowance
+	ps.invalidTx(now)
+	assert.False(ps.isSpammer())
+	ps.invalidTx(now)
+	assert.True(ps.isSpammer())
+	assert.Equal(int64(2), ps.Stats().Invalid)
+}
+
+func TestReactorStopsSpammer(t *testing.T) {
+	assert := assert.New(t)
+	const nTxs = 20
+	// node 1 takes one tx a second from node 0, after a burst of peerBurstSeconds
+	reactors, switches := makeAndConnectMempoolReactors(t, 2, func(i int, config *cfg.MempoolConfig) {
+		if i == 1 {
+			config.PeerMaxTxsRate = 1
+			config.PeerMaxSpam = 2
+		}
+	})
+	defer stopSwitches(switches)
+	peer := switches[1].Peers().List()[0]
+
+	for i := 0; i < nTxs; i++ {
+		assert.Nil(reactors[0].Mempool.CheckTx(types.Tx{byte(i)}, nil))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for switches[1].Peers().Size() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Zero(switches[1].Peers().Size(), "spammer is still connected")
+	stats := peer.Get(types.PeerMempoolStateKey).(*PeerState).Stats()
+	assert.True(stats.Throttled > 2, "throttled %d txs", stats.Throttled)
+	assert.True(reactors[1].Mempool.Size() < nTxs)
+}
+
+func TestReactorReceivesTxBeforeAddPeer(t *testing.T) {
+	assert := assert.New(t)
+	reactors, switches := makeAndConnectMempoolReactors(t, 1, nil)
+	defer stopSwitches(switches)
+
+	// a peer whose txs arrive before the reactor's AddPeer
+	peer := &p2p.Peer{Key: "peer", Data: cmn.NewCMap()}
+	msg := struct{ MempoolMessage }{&TxMessage{Tx: types.Tx("tx")}}
+	assert.NotPanics(func() {
+		reactors[0].Receive(MempoolChannel, peer, wire.BinaryBytes(msg))
+	})
+	assert.Equal(1, reactors[0].Mempool.Size())
+
+	// AddPeer keeps the peer's state
+	ps := peer.Get(types.PeerMempoolStateKey).(*PeerState)
+	reactors[0].AddPeer(peer)
+	assert.True(ps == peer.Get(types.PeerMempoolStateKey).(*PeerState))
+}
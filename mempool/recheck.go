@@ -0,0 +1,189 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/tmlibs/clist"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+/*
+
+After each block, Update() rechecks the txs left in the mempool,
+since the block may have made them invalid.
+
+The recheck runs in the background, recheck_batch_size txs at a time,
+holding the mempool lock only while it sends a batch to the app,
+so new txs can be checked in between. Reap() skips the txs it has yet
+to recheck, rather than waiting for it to finish.
+
+The ABCI CheckTx request only has the tx, so the mempool tells the app
+which CheckTx requests are rechecks with SetOption: each batch is sent between
+SetOption(RecheckOption, "on") and SetOption(RecheckOption, "off")
+on the mempool connection, which handles requests in order.
+
+The app can limit the recheck to the txs that depend on what the block changed.
+It passes RecheckHintsPrefix followed by the JSON encoded RecheckHints
+in the Log of its Commit response, with the senders and other keys the block
+touched. Only txs whose TxHints sender or keys were touched are rechecked,
+along with txs that have neither. Without hints, every tx is rechecked.
+The EndBlock response has no room for hints, so txs that EndBlock
+invalidates must be covered by the touched keys returned in Commit.
+
+*/
+
+const (
+	// RecheckOption is the SetOption key that marks the CheckTx requests in between as rechecks.
+	RecheckOption = "mempool.recheck"
+
+	// RecheckHintsPrefix marks Commit response Logs holding RecheckHints.
+	RecheckHintsPrefix = TxHintsPrefix
+)
+
+// RecheckHints is what the app can tell the mempool about a block in its Commit response.
+type RecheckHints struct {
+	// The senders and keys of the TxHints that the block touched.
+	Touched []string `json:"touched"`
+}
+
+// Log returns the Commit response Log for the hints.
+func (h RecheckHints) Log() string {
+	bz, err := json.Marshal(h)
+	if err != nil {
+		panic(err)
+	}
+	return RecheckHintsPrefix + string(bz)
+}
+
+// parseRecheckHints returns the touched keys in the Commit response Log.
+// It returns nil, so that every tx is rechecked, if the Log has no hints.
+func parseRecheckHints(log string) ([]string, error) {
+	if !bytes.HasPrefix([]byte(log), []byte(RecheckHintsPrefix)) {
+		return nil, nil
+	}
+	var hints RecheckHints
+	if err := json.Unmarshal([]byte(log[len(RecheckHintsPrefix):]), &hints); err != nil {
+		return nil, err
+	}
+	if hints.Touched == nil {
+		hints.Touched = []string{}
+	}
+	return hints.Touched, nil
+}
+
+// touchedTxs returns the elements whose txs depend on the touched keys,
+// or all of them if touched is nil.
+func touchedTxs(elems []*clist.CElement, touched []string) []*clist.CElement {
+	if touched == nil {
+		return elems
+	}
+	touchedSet := make(map[string]struct{}, len(touched))
+	for _, key := range touched {
+		touchedSet[key] = struct{}{}
+	}
+	var dependent []*clist.CElement
+	for _, e := range elems {
+		if e.Value.(*mempoolTx).dependsOn(touchedSet) {
+			dependent = append(dependent, e)
+		}
+	}
+	return dependent
+}
+
+// recheckTxs starts rechecking the elements in the background.
+// A recheck still running from an earlier Update() keeps going,
+// since the txs it has yet to recheck may not be touched by this block.
+// NOTE: unsafe; the caller must hold the lock
+func (mem *Mempool) recheckTxs(elems []*clist.CElement) {
+	if len(elems) == 0 {
+		return
+	}
+	atomic.AddInt32(&mem.rechecking, int32(len(elems)))
+	for _, e := range elems {
+		atomic.AddInt32(&e.Value.(*mempoolTx).rechecking, 1)
+	}
+	go mem.recheckRoutine(elems)
+}
+
+func (mem *Mempool) recheckRoutine(elems []*clist.CElement) {
+	batchSize := mem.config.RecheckBatchSize
+	if batchSize <= 0 {
+		batchSize = len(elems)
+	}
+	for start := 0; start < len(elems); start += batchSize {
+		end := start + batchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+
+		mem.proxyMtx.Lock()
+		mem.proxyAppConn.SetOptionAsync(RecheckOption, "on")
+		for _, e := range elems[start:end] {
+			mem.recheckTx(e)
+		}
+		mem.proxyAppConn.SetOptionAsync(RecheckOption, "off")
+		mem.proxyAppConn.FlushAsync()
+		mem.proxyMtx.Unlock()
+	}
+}
+
+// recheckTx sends the element's tx to the app again, if it is still in the mempool.
+// NOTE: unsafe; the caller must hold the lock
+func (mem *Mempool) recheckTx(e *clist.CElement) {
+	memTx := e.Value.(*mempoolTx)
+	if !mem.txsByPriority.Contains(e) {
+		// committed or evicted since the recheck started
+		mem.recheckDone(memTx)
+		return
+	}
+	reqRes := mem.proxyAppConn.CheckTxAsync(memTx.tx)
+	reqRes.SetCallback(func(res *abci.Response) {
+		mem.resCbRecheck(e, res)
+		mem.recheckDone(memTx)
+	})
+}
+
+// resCbRecheck handles the recheck of the element's tx.
+// It may be called without the lock, concurrently with CheckTx() and Update().
+func (mem *Mempool) resCbRecheck(e *clist.CElement, res *abci.Response) {
+	switch r := res.Value.(type) {
+	case *abci.Response_CheckTx:
+		memTx := e.Value.(*mempoolTx)
+		if r.CheckTx.Code == abci.CodeType_OK {
+			// Good, but the priority may have changed.
+			hints := mem.parseTxHints(r.CheckTx)
+			mem.txsByPriority.UpdatePriority(e, hints.Priority)
+		} else if mem.removeTx(e) {
+			// Tx became invalidated due to newly committed block.
+
+			// remove from cache (it might be good later)
+			mem.cache.Remove(memTx.tx)
+			types.FireEventEvictTx(mem.evsw, types.EventDataEvictTx{memTx.tx, "invalid"})
+		}
+	default:
+		// ignore other messages
+	}
+}
+
+// recheckDone counts the tx as rechecked.
+func (mem *Mempool) recheckDone(memTx *mempoolTx) {
+	atomic.AddInt32(&memTx.rechecking, -1)
+	if atomic.AddInt32(&mem.rechecking, -1) == 0 {
+		mem.logger.Info("Done rechecking txs")
+	}
+}
+
+// waitForRecheck blocks until the txs are rechecked.
+// Reap() doesn't wait; this is for the tests.
+// NOTE: the caller must not hold the lock, which the recheck needs.
+func (mem *Mempool) waitForRecheck() {
+	for atomic.LoadInt32(&mem.rechecking) > 0 {
+		// TODO: Something better?
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -0,0 +1,224 @@
+package mempool
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	auto "github.com/tendermint/tmlibs/autofile"
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+/*
+
+The mempool WAL has a line for each tx the mempool receives, hex encoded,
+written before the tx is checked by the app. When a tx leaves the mempool,
+eg. because it was committed or removed, a line with "-" and its hash is
+appended, so it's dropped when the WAL is read.
+On start, ReplayWAL() runs the txs left in the WAL through CheckTx again,
+so pending txs survive a restart.
+
+Since removals are just appended, the WAL is only compacted on replay,
+and when it grows past wal_size_limit, by rewriting it with just the txs
+in the mempool. The new WAL is written to a temporary file and renamed
+over the old one, so a crash leaves either the old or the new WAL in place.
+
+*/
+
+const (
+	walFile        = "wal"
+	walCompactFile = "wal.compact"
+	walRemoved     = "-" // prefixes the hash of a tx that left the mempool
+)
+
+func (mem *Mempool) walPath() string {
+	return filepath.Join(mem.config.WalDir(), walFile)
+}
+
+func (mem *Mempool) initWAL() {
+	walDir := mem.config.WalDir()
+	if walDir != "" {
+		err := cmn.EnsureDir(walDir, 0700)
+		if err != nil {
+			cmn.PanicSanity(errors.Wrap(err, "Error ensuring Mempool wal dir"))
+		}
+		af, err := auto.OpenAutoFile(mem.walPath())
+		if err != nil {
+			cmn.PanicSanity(errors.Wrap(err, "Error opening Mempool wal file"))
+		}
+		mem.wal = af
+		mem.walCompactSize, _ = af.Size()
+	}
+}
+
+func (mem *Mempool) writeWAL(tx types.Tx) {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if mem.wal == nil {
+		return
+	}
+	// TODO: Notify administrators when WAL fails
+	mem.wal.Write([]byte(hex.EncodeToString(tx) + "\n"))
+}
+
+// writeWALRemoved records that the tx left the mempool.
+func (mem *Mempool) writeWALRemoved(tx types.Tx) {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if mem.wal == nil {
+		return
+	}
+	mem.wal.Write([]byte(walRemoved + hex.EncodeToString(tx.Hash()) + "\n"))
+}
+
+// ReplayWAL runs the txs in the WAL through CheckTx,
+// then compacts the WAL to the txs that made it into the mempool.
+func (mem *Mempool) ReplayWAL() error {
+	if mem.wal == nil {
+		return nil
+	}
+	txs, err := readWAL(mem.walPath())
+	if err != nil {
+		return errors.Wrap(err, "Error reading Mempool wal")
+	}
+	mem.logger.Info("Replaying Mempool wal", "txs", len(txs))
+	for _, tx := range txs {
+		if err := mem.CheckTx(tx, nil); err != nil {
+			mem.logger.Info("Could not replay tx", "tx", tx, "err", err)
+		}
+	}
+
+	mem.proxyMtx.Lock()
+	defer mem.proxyMtx.Unlock()
+	// wait for the responses, so the txs are in the mempool
+	mem.proxyAppConn.FlushSync()
+	mem.compactWAL()
+	mem.logger.Info("Replayed Mempool wal", "size", mem.Size())
+	return nil
+}
+
+// readWAL returns the txs in the WAL file, less the ones removed after them.
+// Lines that are not hex, eg. from the older unencoded WAL format, are skipped.
+func readWAL(path string) ([]types.Tx, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var txs []types.Tx
+	removed := make(map[int]bool)
+	indexes := make(map[string][]int) // tx hash -> indexes in txs
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 2*types.MaxBlockSize+1) // hex encoded, plus the newline
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, walRemoved) {
+			hash, err := hex.DecodeString(line[len(walRemoved):])
+			if err != nil {
+				continue
+			}
+			for _, i := range indexes[string(hash)] {
+				removed[i] = true
+			}
+			delete(indexes, string(hash))
+			continue
+		}
+		tx, err := hex.DecodeString(line)
+		if err != nil || len(tx) == 0 {
+			continue
+		}
+		hash := string(types.Tx(tx).Hash())
+		indexes[hash] = append(indexes[hash], len(txs))
+		txs = append(txs, tx)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []types.Tx
+	for i, tx := range txs {
+		if !removed[i] {
+			pending = append(pending, tx)
+		}
+	}
+	return pending, nil
+}
+
+// walNeedsRotation returns true if the WAL has grown past wal_size_limit
+// since it was last compacted.
+func (mem *Mempool) walNeedsRotation() bool {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if mem.wal == nil || mem.config.WalSizeLimit <= 0 {
+		return false
+	}
+	size, err := mem.wal.Size()
+	if err != nil {
+		return false
+	}
+	// if the mempool itself is bigger than the limit, let the wal double first
+	return size > mem.config.WalSizeLimit && size > 2*mem.walCompactSize
+}
+
+// compactWAL rewrites the WAL with just the txs in the mempool, in arrival order.
+// NOTE: unsafe; Lock/Unlock must be managed by caller
+func (mem *Mempool) compactWAL() {
+	if err := mem.rewriteWAL(); err != nil {
+		// keep appending to the old wal, it still has every pending tx
+		mem.logger.Error("Error compacting Mempool wal", "err", err)
+	}
+}
+
+// rewriteWAL holds walMtx throughout, so a tx removed meanwhile
+// has its removal appended to the new WAL rather than the closed one.
+func (mem *Mempool) rewriteWAL() error {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if mem.wal == nil {
+		return nil
+	}
+
+	compactPath := filepath.Join(mem.config.WalDir(), walCompactFile)
+	f, err := os.Create(compactPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		w.WriteString(hex.EncodeToString(e.Value.(*mempoolTx).tx) + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := mem.wal.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(compactPath, mem.walPath()); err != nil {
+		return err
+	}
+	af, err := auto.OpenAutoFile(mem.walPath())
+	if err != nil {
+		cmn.PanicSanity(errors.Wrap(err, "Error opening Mempool wal file"))
+	}
+	mem.wal = af
+	mem.walCompactSize, _ = af.Size()
+	return nil
+}
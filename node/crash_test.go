@@ -0,0 +1,154 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/fail"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+)
+
+// These tests crash a node at each registered fail point in turn,
+// restart it, and check that the handshake replays the app to a consistent state.
+// The node runs in a subprocess (the test binary itself, see TestCrashHelper)
+// so the crash is a real process exit.
+
+const (
+	envCrashRoot = "TM_CRASH_TEST_ROOT"
+	envCrashMode = "TM_CRASH_TEST_MODE"
+
+	crashHit     = 3 // crash on this hit of a fail point, so there are blocks to recover
+	crashTimeout = 30 * time.Second
+)
+
+// Fail points that can only be reached after an earlier crash
+// has left a block for the handshake to replay.
+var crashSetup = map[string][]string{
+	"consensus/Handshaker/replayBlock": {"consensus/finalizeCommit/afterSaveBlock"},
+}
+
+func TestCrashRecovery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crash recovery tests in short mode")
+	}
+	for _, point := range fail.Points() {
+		config := cfg.ResetTestRoot("node_crash_test")
+
+		for _, setup := range crashSetup[point] {
+			runCrashHelper(t, config.RootDir, "crash", fmt.Sprintf("%s:%d", setup, crashHit), fail.ExitCode)
+		}
+		hit := crashHit
+		if len(crashSetup[point]) > 0 {
+			hit = 1
+		}
+		runCrashHelper(t, config.RootDir, "crash", fmt.Sprintf("%s:%d", point, hit), fail.ExitCode)
+		runCrashHelper(t, config.RootDir, "recover", "", 0)
+		t.Logf("Recovered from crash at %s", point)
+	}
+}
+
+// runCrashHelper runs TestCrashHelper in a subprocess and checks its exit code.
+func runCrashHelper(t *testing.T, root, mode, failPoint string, exitCode int) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelper$", "-test.v")
+	cmd.Env = append(os.Environ(),
+		envCrashRoot+"="+root,
+		envCrashMode+"="+mode,
+		fail.EnvFailPoint+"="+failPoint,
+	)
+	out := new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = out, out
+
+	err := cmd.Run()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+	} else if err != nil {
+		t.Fatalf("Error running crash helper: %v", err)
+	}
+	if code != exitCode {
+		t.Fatalf("Crash helper (mode=%s, %s=%s) exited with %d, expected %d. Output:\n%s",
+			mode, fail.EnvFailPoint, failPoint, code, exitCode, out.String())
+	}
+}
+
+// TestCrashHelper is not a real test. It runs a node for TestCrashRecovery
+// and does nothing unless called from there.
+// In "crash" mode, it feeds the node txs until it exits at the fail point.
+// In "recover" mode, it checks the app is synced after the handshake
+// and waits for the node to make a new block.
+func TestCrashHelper(t *testing.T) {
+	root := os.Getenv(envCrashRoot)
+	if root == "" {
+		t.Skip("only run as a subprocess of TestCrashRecovery")
+	}
+
+	config := cfg.TestConfig().SetRoot(root)
+	config.ProxyApp = "persistent_dummy"
+	config.DBBackend = "leveldb"
+	config.RPC.ListenAddress = ""
+	config.RPC.GRPCListenAddress = ""
+
+	n := NewNodeDefault(config, log.TestingLogger())
+
+	newBlockCh := make(chan struct{}, 1)
+	types.AddListenerForEvent(n.EventSwitch(), "crash_test", types.EventStringNewBlock(), func(data types.TMEventData) {
+		select {
+		case newBlockCh <- struct{}{}:
+		default:
+		}
+	})
+
+	switch os.Getenv(envCrashMode) {
+	case "crash":
+		if _, err := n.Start(); err != nil {
+			t.Fatal(err)
+		}
+		mempool := n.MempoolReactor().Mempool
+		timeout := time.After(crashTimeout)
+		for {
+			select {
+			case <-timeout:
+				t.Fatalf("Timed out waiting to crash at %s", os.Getenv(fail.EnvFailPoint))
+			default:
+			}
+			tx := []byte(cmn.Fmt("%s=%s", cmn.RandStr(8), cmn.RandStr(8)))
+			mempool.CheckTx(tx, nil)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+	case "recover":
+		// the handshake ran in NewNode, so the app must be synced up
+		res, err := n.ProxyApp().Query().InfoSync()
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := n.ConsensusState().GetState()
+		if int(res.LastBlockHeight) != n.BlockStore().Height() {
+			t.Fatalf("App height %d does not match store height %d", res.LastBlockHeight, n.BlockStore().Height())
+		}
+		if !bytes.Equal(res.LastBlockAppHash, state.AppHash) {
+			t.Fatalf("App hash %X does not match state app hash %X", res.LastBlockAppHash, state.AppHash)
+		}
+
+		if _, err := n.Start(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-newBlockCh:
+		case <-time.After(crashTimeout):
+			t.Fatal("Timed out waiting for a new block after recovery")
+		}
+		n.Stop()
+
+	default:
+		t.Fatalf("Unknown crash test mode %q", os.Getenv(envCrashMode))
+	}
+}
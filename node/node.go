@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
@@ -103,13 +104,21 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 	}
 	state.TxIndexer = txIndexer
 
-	// Generate node PrivKey
-	privKey := crypto.GenPrivKeyEd25519()
+	// Load the node's p2p key, generating it on the first start,
+	// so the node keeps its ID across restarts
+	nodeKey, err := p2p.LoadOrGenNodeKey(config.NodeKeyFile())
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load node key: %v", err))
+	}
+	privKey, err := nodeKey.PrivKeyEd25519()
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load node key: %v", err))
+	}
 
 	// Make event switch
 	eventSwitch := types.NewEventSwitch()
 	eventSwitch.SetLogger(logger.With("module", "types"))
-	_, err := eventSwitch.Start()
+	_, err = eventSwitch.Start()
 	if err != nil {
 		cmn.Exit(cmn.Fmt("Failed to start switch: %v", err))
 	}
@@ -137,8 +146,11 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 
 	// Make MempoolReactor
 	mempoolLogger := logger.With("module", "mempool")
-	mempool := mempl.NewMempool(config.Mempool, proxyApp.Mempool())
+	mempool := mempl.NewMempool(config.Mempool, proxyApp.Mempool(), state.LastBlockHeight)
 	mempool.SetLogger(mempoolLogger)
+	if err := mempool.ReplayWAL(); err != nil {
+		cmn.Exit(cmn.Fmt("Failed to replay mempool wal: %v", err))
+	}
 	mempoolReactor := mempl.NewMempoolReactor(config.Mempool, mempool)
 	mempoolReactor.SetLogger(mempoolLogger)
 
@@ -155,6 +167,7 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 
 	sw := p2p.NewSwitch(config.P2P)
 	sw.SetLogger(p2pLogger)
+	sw.SetTransport(p2p.TCPTransport{UPNP: !config.P2P.SkipUPNP, Logger: p2pLogger})
 	sw.AddReactor("MEMPOOL", mempoolReactor)
 	sw.AddReactor("BLOCKCHAIN", bcReactor)
 	sw.AddReactor("CONSENSUS", consensusReactor)
@@ -164,7 +177,13 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 	if config.P2P.PexReactor {
 		addrBook = p2p.NewAddrBook(config.P2P.AddrBookFile(), config.P2P.AddrBookStrict)
 		addrBook.SetLogger(p2pLogger.With("book", config.P2P.AddrBookFile()))
+		if config.P2P.PrivatePeerIDs != "" {
+			addrBook.AddPrivateIDs(strings.Split(config.P2P.PrivatePeerIDs, ","))
+		}
+		addrBook.SetBanPolicy(config.P2P.TrustThreshold, time.Duration(config.P2P.BanPeriod)*time.Millisecond)
+		sw.SetAddrBook(addrBook)
 		pexReactor := p2p.NewPEXReactor(addrBook)
+		pexReactor.SetSeedMode(config.P2P.SeedMode)
 		pexReactor.SetLogger(p2pLogger)
 		sw.AddReactor("PEX", pexReactor)
 	}
@@ -234,13 +253,31 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 func (n *Node) OnStart() error {
 	// Create & add listener
 	protocol, address := ProtocolAndAddress(n.config.P2P.ListenAddress)
-	l := p2p.NewDefaultListener(protocol, address, n.config.P2P.SkipUPNP, n.Logger.With("module", "p2p"))
+	if protocol != "tcp" {
+		return errors.New("Unsupported p2p protocol " + protocol)
+	}
+	addr, err := p2p.NewNetAddressString(address)
+	if err != nil {
+		return err
+	}
+	l, err := n.sw.Transport().Listen(addr)
+	if err != nil {
+		return err
+	}
 	n.sw.AddListener(l)
 
+	// Add persistent peers, which the switch dials when it starts
+	if n.config.P2P.PersistentPeers != "" {
+		persistentPeers := strings.Split(n.config.P2P.PersistentPeers, ",")
+		if err := n.sw.AddPersistentPeers(persistentPeers); err != nil {
+			return err
+		}
+	}
+
 	// Start the switch
 	n.sw.SetNodeInfo(n.makeNodeInfo())
 	n.sw.SetNodePrivKey(n.privKey)
-	_, err := n.sw.Start()
+	_, err = n.sw.Start()
 	if err != nil {
 		return err
 	}
@@ -412,6 +449,10 @@ func (n *Node) makeNodeInfo() *p2p.NodeInfo {
 		},
 	}
 
+	if n.config.Mempool.AnnounceTxs {
+		nodeInfo.Other = append(nodeInfo.Other, mempl.NodeInfoAnnounceTxs)
+	}
+
 	// include git hash in the nodeInfo if available
 	// TODO: use ld-flags
 	/*if rev, err := cmn.ReadFile(n.config.GetString("revision_file")); err == nil {
@@ -85,6 +85,10 @@ type AddrBook struct {
 	rand              *rand.Rand
 	key               string
 	ourAddrs          map[string]*NetAddress
+	privateIDs        map[ID]struct{}
+	trust             map[ID]*trustMetric
+	trustThreshold    float64
+	banPeriod         time.Duration
 	addrLookup        map[string]*knownAddress // new & old
 	addrNew           []map[string]*knownAddress
 	addrOld           []map[string]*knownAddress
@@ -99,6 +103,10 @@ func NewAddrBook(filePath string, routabilityStrict bool) *AddrBook {
 	am := &AddrBook{
 		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
 		ourAddrs:          make(map[string]*NetAddress),
+		privateIDs:        make(map[ID]struct{}),
+		trust:             make(map[ID]*trustMetric),
+		trustThreshold:    defaultTrustThreshold,
+		banPeriod:         defaultBanPeriod,
 		addrLookup:        make(map[string]*knownAddress),
 		filePath:          filePath,
 		routabilityStrict: routabilityStrict,
@@ -148,6 +156,43 @@ func (a *AddrBook) AddOurAddress(addr *NetAddress) {
 	a.ourAddrs[addr.String()] = addr
 }
 
+// AddPrivateIDs marks the peers with the IDs as private. Their addresses
+// are never added to the book, so they're never gossiped to other peers.
+func (a *AddrBook) AddPrivateIDs(ids []string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for _, id := range ids {
+		a.privateIDs[ID(id)] = struct{}{}
+	}
+	for _, ka := range a.addrLookup {
+		if _, ok := a.privateIDs[ka.Addr.ID]; ok {
+			a.removeFromAllBuckets(ka)
+		}
+	}
+}
+
+// SetBanPolicy sets the trust score below which peers are banned,
+// and for how long. A threshold or period of 0 disables banning.
+func (a *AddrBook) SetBanPolicy(trustThreshold float64, banPeriod time.Duration) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.trustThreshold = trustThreshold
+	a.banPeriod = banPeriod
+}
+
+// IsPrivate returns true if the address is of a private peer.
+func (a *AddrBook) IsPrivate(addr *NetAddress) bool {
+	return a.IsPrivateID(addr.ID)
+}
+
+// IsPrivateID returns true if the peer with the ID is private.
+func (a *AddrBook) IsPrivateID(id ID) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	_, ok := a.privateIDs[id]
+	return ok
+}
+
 func (a *AddrBook) OurAddresses() []*NetAddress {
 	addrs := []*NetAddress{}
 	for _, addr := range a.ourAddrs {
@@ -203,32 +248,39 @@ func (a *AddrBook) PickAddress(newBias int) *NetAddress {
 		for len(bucket) == 0 {
 			bucket = a.addrOld[a.rand.Intn(len(a.addrOld))]
 		}
-		// pick a random ka from bucket.
-		randIndex := a.rand.Intn(len(bucket))
-		for _, ka := range bucket {
-			if randIndex == 0 {
-				return ka.Addr
-			}
-			randIndex--
-		}
-		cmn.PanicSanity("Should not happen")
+		return a.pickFromBucket(bucket)
 	} else {
 		// pick random New bucket.
 		var bucket map[string]*knownAddress = nil
 		for len(bucket) == 0 {
 			bucket = a.addrNew[a.rand.Intn(len(a.addrNew))]
 		}
-		// pick a random ka from bucket.
-		randIndex := a.rand.Intn(len(bucket))
-		for _, ka := range bucket {
-			if randIndex == 0 {
-				return ka.Addr
-			}
-			randIndex--
+		return a.pickFromBucket(bucket)
+	}
+}
+
+// pickFromBucket picks an address from the bucket at random,
+// with a chance proportional to the trust score of its peer.
+func (a *AddrBook) pickFromBucket(bucket map[string]*knownAddress) *NetAddress {
+	now := time.Now()
+	kas := make([]*knownAddress, 0, len(bucket))
+	scores := make([]float64, 0, len(bucket))
+	total := 0.0
+	for _, ka := range bucket {
+		score := a.trustScore(ka.Addr.ID, now)
+		kas = append(kas, ka)
+		scores = append(scores, score)
+		total += score
+	}
+	r := a.rand.Float64() * total
+	for i, ka := range kas {
+		r -= scores[i]
+		if r < 0 {
+			return ka.Addr
 		}
-		cmn.PanicSanity("Should not happen")
 	}
-	return nil
+	// rounding
+	return kas[len(kas)-1].Addr
 }
 
 func (a *AddrBook) MarkGood(addr *NetAddress) {
@@ -254,8 +306,7 @@ func (a *AddrBook) MarkAttempt(addr *NetAddress) {
 	ka.markAttempt()
 }
 
-// MarkBad currently just ejects the address. In the future, consider
-// blacklisting.
+// MarkBad currently just ejects the address. To keep a misbehaving peer out, use Ban.
 func (a *AddrBook) MarkBad(addr *NetAddress) {
 	a.RemoveAddress(addr)
 }
@@ -272,6 +323,81 @@ func (a *AddrBook) RemoveAddress(addr *NetAddress) {
 	a.removeFromAllBuckets(ka)
 }
 
+// ListOfKnownAddresses returns copies of the known addresses, with their history.
+func (a *AddrBook) ListOfKnownAddresses() []*knownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	kas := make([]*knownAddress, 0, len(a.addrLookup))
+	for _, ka := range a.addrLookup {
+		kaCopy := *ka
+		kas = append(kas, &kaCopy)
+	}
+	return kas
+}
+
+/* Trust */
+
+// RecordPeerEvent updates the trust metric of the peer with the ID, and bans the peer
+// if its score falls below the trust threshold. It returns true if the peer is banned.
+func (a *AddrBook) RecordPeerEvent(id ID, event PeerEvent) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	now := time.Now()
+	tm := a.trustMetric(id)
+	tm.record(event, now)
+	if tm.isBanned(now) {
+		return true
+	}
+	if !a.banningEnabled() {
+		return false
+	}
+	if score := tm.score(now); score < a.trustThreshold {
+		a.Logger.Info("Banning peer", "id", id, "score", score, "event", event, "until", now.Add(a.banPeriod))
+		a.ban(id, now)
+		return tm.isBanned(now)
+	}
+	return false
+}
+
+// Ban bans the peer with the ID for the ban period, for misbehaving,
+// and removes its addresses.
+func (a *AddrBook) Ban(id ID) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if !a.banningEnabled() {
+		return
+	}
+	now := time.Now()
+	a.Logger.Info("Banning peer", "id", id, "until", now.Add(a.banPeriod))
+	a.ban(id, now)
+}
+
+// IsBanned returns true if the peer with the ID is banned.
+func (a *AddrBook) IsBanned(id ID) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.isBanned(id, time.Now())
+}
+
+// TrustScore returns the trust score of the peer with the ID.
+func (a *AddrBook) TrustScore(id ID) float64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.trustScore(id, time.Now())
+}
+
+// PeerTrusts returns the trust metrics of the peers we have any for.
+func (a *AddrBook) PeerTrusts() []PeerTrust {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	now := time.Now()
+	pts := make([]PeerTrust, 0, len(a.trust))
+	for id, tm := range a.trust {
+		pts = append(pts, tm.peerTrust(id, now))
+	}
+	return pts
+}
+
 /* Peer exchange */
 
 // GetSelection randomly selects some addresses (old & new). Suitable for peer-exchange protocols.
@@ -312,6 +438,7 @@ func (a *AddrBook) GetSelection() []*NetAddress {
 type addrBookJSON struct {
 	Key   string
 	Addrs []*knownAddress
+	Trust map[ID]*trustMetric
 }
 
 func (a *AddrBook) saveToFile(filePath string) {
@@ -324,10 +451,18 @@ func (a *AddrBook) saveToFile(filePath string) {
 	for _, ka := range a.addrLookup {
 		addrs = append(addrs, ka)
 	}
+	// Forget the trust metrics that have decayed away
+	now := time.Now()
+	for id, tm := range a.trust {
+		if tm.isForgettable(now) {
+			delete(a.trust, id)
+		}
+	}
 
 	aJSON := &addrBookJSON{
 		Key:   a.key,
 		Addrs: addrs,
+		Trust: a.trust,
 	}
 
 	jsonBytes, err := json.MarshalIndent(aJSON, "", "\t")
@@ -366,8 +501,23 @@ func (a *AddrBook) loadFromFile(filePath string) bool {
 	// Restore all the fields...
 	// Restore the key
 	a.key = aJSON.Key
+	// Restore the trust metrics, and so the bans
+	for id, tm := range aJSON.Trust {
+		a.trust[id] = tm
+	}
 	// Restore .addrNew & .addrOld
+	now := time.Now()
 	for _, ka := range aJSON.Addrs {
+		if ka.Addr.ID == "" {
+			// Saved before addresses had IDs; it can't be authenticated.
+			continue
+		}
+		if _, ok := a.privateIDs[ka.Addr.ID]; ok {
+			continue
+		}
+		if a.isBanned(ka.Addr.ID, now) {
+			continue
+		}
 		for _, bucketIndex := range ka.Buckets {
 			bucket := a.getBucket(ka.BucketType, bucketIndex)
 			bucket[ka.Addr.String()] = ka
@@ -534,6 +684,11 @@ func (a *AddrBook) pickOldest(bucketType byte, bucketIdx int) *knownAddress {
 }
 
 func (a *AddrBook) addAddress(addr, src *NetAddress) {
+	if addr.ID == "" {
+		// Can't authenticate the peer when we dial it.
+		a.Logger.Error(cmn.Fmt("Cannot add address %v without ID", addr))
+		return
+	}
 	if a.routabilityStrict && !addr.Routable() {
 		a.Logger.Error(cmn.Fmt("Cannot add non-routable address %v", addr))
 		return
@@ -542,6 +697,13 @@ func (a *AddrBook) addAddress(addr, src *NetAddress) {
 		// Ignore our own listener address.
 		return
 	}
+	if _, ok := a.privateIDs[addr.ID]; ok {
+		// Keep private peers secret.
+		return
+	}
+	if a.isBanned(addr.ID, time.Now()) {
+		return
+	}
 
 	ka := a.addrLookup[addr.String()]
 
@@ -569,6 +731,45 @@ func (a *AddrBook) addAddress(addr, src *NetAddress) {
 	a.Logger.Info("Added new address", "address", addr, "total", a.size())
 }
 
+// trustMetric returns the trust metric of the peer with the ID, adding it if there is none.
+func (a *AddrBook) trustMetric(id ID) *trustMetric {
+	tm, ok := a.trust[id]
+	if !ok {
+		tm = &trustMetric{}
+		a.trust[id] = tm
+	}
+	return tm
+}
+
+func (a *AddrBook) trustScore(id ID, now time.Time) float64 {
+	if tm, ok := a.trust[id]; ok {
+		return tm.score(now)
+	}
+	// nothing known: the score of an empty metric
+	return 0.5
+}
+
+func (a *AddrBook) isBanned(id ID, now time.Time) bool {
+	tm, ok := a.trust[id]
+	return ok && tm.isBanned(now)
+}
+
+// banningEnabled returns false if SetBanPolicy disabled banning.
+func (a *AddrBook) banningEnabled() bool {
+	return a.trustThreshold > 0 && a.banPeriod > 0
+}
+
+// ban bans the peer with the ID for the ban period, and removes its addresses.
+func (a *AddrBook) ban(id ID, now time.Time) {
+	a.trustMetric(id).BannedUntil = now.Add(a.banPeriod)
+	for _, ka := range a.addrLookup {
+		if ka.Addr.ID == id {
+			a.Logger.Info("Remove address of banned peer from book", "addr", ka.Addr)
+			a.removeFromAllBuckets(ka)
+		}
+	}
+}
+
 // Make space in the new buckets by expiring the really bad entries.
 // If no bad entries are available we remove the oldest.
 func (a *AddrBook) expireNew(bucketIdx int) {
@@ -1,12 +1,14 @@
 package p2p
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	cmn "github.com/tendermint/tmlibs/common"
 	"github.com/tendermint/tmlibs/log"
 )
 
@@ -148,7 +150,8 @@ func randIPv4Address(t *testing.T) *NetAddress {
 			rand.Intn(255),
 		)
 		port := rand.Intn(65535-1) + 1
-		addr, err := NewNetAddressString(fmt.Sprintf("%v:%v", ip, port))
+		id := ID(hex.EncodeToString(cmn.RandBytes(IDByteLength)))
+		addr, err := NewNetAddressString(IDAddressString(id, fmt.Sprintf("%v:%v", ip, port)))
 		assert.Nil(t, err, "error generating rand network address")
 		if addr.Routable() {
 			return addr
@@ -172,3 +175,98 @@ func TestAddrBookRemoveAddress(t *testing.T) {
 	book.RemoveAddress(nonExistingAddr)
 	assert.Equal(t, 0, book.Size())
 }
+
+func TestAddrBookRequiresID(t *testing.T) {
+	fname := createTempFileName("addrbook_test")
+	book := NewAddrBook(fname, true)
+	book.SetLogger(log.TestingLogger())
+
+	addr := randIPv4Address(t)
+	noID := NewNetAddressIPPort(addr.IP, addr.Port)
+	book.AddAddress(noID, addr)
+	assert.Equal(t, 0, book.Size())
+
+	book.AddAddress(addr, noID)
+	assert.Equal(t, 1, book.Size())
+}
+
+func TestAddrBookPrivateIDs(t *testing.T) {
+	fname := createTempFileName("addrbook_test")
+	book := NewAddrBook(fname, true)
+	book.SetLogger(log.TestingLogger())
+
+	addr, private := randIPv4Address(t), randIPv4Address(t)
+	book.AddAddress(addr, addr)
+	book.AddAddress(private, addr)
+	assert.Equal(t, 2, book.Size())
+
+	// marking an ID private removes its address
+	book.AddPrivateIDs([]string{string(private.ID)})
+	assert.Equal(t, 1, book.Size())
+	assert.True(t, book.IsPrivate(private))
+	assert.False(t, book.IsPrivate(addr))
+
+	book.AddAddress(private, addr)
+	assert.Equal(t, 1, book.Size())
+}
+
+func TestAddrBookBansUntrustedPeers(t *testing.T) {
+	fname := createTempFileName("addrbook_test")
+	book := NewAddrBook(fname, true)
+	book.SetLogger(log.TestingLogger())
+
+	addr, other := randIPv4Address(t), randIPv4Address(t)
+	book.AddAddress(addr, addr)
+	book.AddAddress(other, addr)
+	assert.Equal(t, 2, book.Size())
+
+	assert.False(t, book.RecordPeerEvent(addr.ID, PeerEventUsefulData))
+	assert.True(t, book.TrustScore(addr.ID) > book.TrustScore(other.ID))
+
+	// banning a peer removes its address, and keeps it out
+	assert.False(t, book.RecordPeerEvent(addr.ID, PeerEventInvalidBlock))
+	assert.True(t, book.RecordPeerEvent(addr.ID, PeerEventInvalidBlock))
+	assert.True(t, book.IsBanned(addr.ID))
+	assert.Equal(t, 1, book.Size())
+	book.AddAddress(addr, other)
+	assert.Equal(t, 1, book.Size())
+
+	// the ban survives a restart
+	book.saveToFile(fname)
+	book = NewAddrBook(fname, true)
+	book.SetLogger(log.TestingLogger())
+	book.loadFromFile(fname)
+	assert.True(t, book.IsBanned(addr.ID))
+	assert.False(t, book.IsBanned(other.ID))
+	assert.Equal(t, 1, book.Size())
+	if assert.Len(t, book.PeerTrusts(), 1) {
+		assert.Equal(t, addr.ID, book.PeerTrusts()[0].ID)
+		assert.False(t, book.PeerTrusts()[0].BannedUntil.IsZero())
+	}
+
+	// marking an address bad just removes it
+	book.MarkBad(other)
+	assert.False(t, book.IsBanned(other.ID))
+	assert.Zero(t, book.Size())
+	book.AddAddress(other, other)
+	assert.Equal(t, 1, book.Size())
+
+	// banning removes it, and keeps it out
+	book.Ban(other.ID)
+	assert.True(t, book.IsBanned(other.ID))
+	assert.Zero(t, book.Size())
+	book.AddAddress(other, other)
+	assert.Zero(t, book.Size())
+
+	// a ban period of 0 disables banning, and keeps the addresses
+	book.SetBanPolicy(defaultTrustThreshold, 0)
+	untrusted := randIPv4Address(t)
+	book.AddAddress(untrusted, untrusted)
+	assert.Equal(t, 1, book.Size())
+	for i := 0; i < 10; i++ {
+		assert.False(t, book.RecordPeerEvent(untrusted.ID, PeerEventInvalidBlock))
+	}
+	book.Ban(untrusted.ID)
+	assert.False(t, book.IsBanned(untrusted.ID))
+	assert.Equal(t, 1, book.Size())
+}
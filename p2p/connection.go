@@ -33,6 +33,9 @@ const (
 	defaultRecvMessageCapacity = 22020096      // 21MB
 	defaultRecvRate            = int64(512000) // 500KB/s
 	defaultSendTimeout         = 10 * time.Second
+
+	// how long to wait before trying again to send from channels over their send rate.
+	throttledChannelWait = 50 * time.Millisecond
 )
 
 type receiveCbFunc func(chID byte, msgBytes []byte)
@@ -62,6 +65,12 @@ The message `msg` is serialized using the `tendermint/wire` submodule's
 queue is full.
 
 Inbound message bytes are handled with an onReceive callback function.
+
+The bandwidth of the connection is limited by its send and recv rates, by the
+node-wide max send and recv rates, shared by all the connections with the
+same config, and for each channel by the rates in its descriptor. A channel
+over its send rate is skipped until it's under again, so it doesn't hold up the
+others, but a channel over its recv rate blocks reading from the connection.
 */
 type MConnection struct {
 	cmn.BaseService
@@ -80,6 +89,10 @@ type MConnection struct {
 	errored     uint32
 	config      *MConnConfig
 
+	sendThrottled     int64  // atomic. ns spent waiting to send because of the send rates
+	recvThrottled     int64  // atomic. ns spent waiting to recv because of the recv rates
+	sendWakeScheduled uint32 // atomic. 1 if the sendRoutine will be woken up for throttled channels
+
 	quit         chan struct{}
 	flushTimer   *cmn.ThrottleTimer // flush writes as necessary but throttled.
 	pingTimer    *cmn.RepeatTimer   // send pings periodically
@@ -90,11 +103,20 @@ type MConnection struct {
 }
 
 // MConnConfig is a MConnection configuration.
+// The rates are in bytes/s, and 0 means no limit.
 type MConnConfig struct {
 	SendRate int64 `mapstructure:"send_rate"`
 	RecvRate int64 `mapstructure:"recv_rate"`
 
+	// Limits on the total rates of all the connections with this config
+	MaxSendRate int64 `mapstructure:"max_send_rate"`
+	MaxRecvRate int64 `mapstructure:"max_recv_rate"`
+
 	flushThrottle time.Duration
+
+	// monitor the total rates of all the connections with this config
+	sendBudget *flow.Monitor
+	recvBudget *flow.Monitor
 }
 
 // DefaultMConnConfig returns the default config.
@@ -103,6 +125,8 @@ func DefaultMConnConfig() *MConnConfig {
 		SendRate:      defaultSendRate,
 		RecvRate:      defaultRecvRate,
 		flushThrottle: flushThrottle,
+		sendBudget:    flow.New(0, 0),
+		recvBudget:    flow.New(0, 0),
 	}
 }
 
@@ -193,6 +217,22 @@ func (c *MConnection) flush() {
 	}
 }
 
+// sent counts bytes sent by the connection.
+func (c *MConnection) sent(n int) {
+	c.sendMonitor.Update(n)
+	if c.config.sendBudget != nil {
+		c.config.sendBudget.Update(n)
+	}
+}
+
+// received counts bytes received by the connection.
+func (c *MConnection) received(n int) {
+	c.recvMonitor.Update(n)
+	if c.config.recvBudget != nil {
+		c.config.recvBudget.Update(n)
+	}
+}
+
 // Catch panics, usually caused by remote disconnects.
 func (c *MConnection) _recover() {
 	if r := recover(); r != nil {
@@ -302,12 +342,12 @@ FOR_LOOP:
 		case <-c.pingTimer.Ch:
 			c.Logger.Debug("Send Ping")
 			wire.WriteByte(packetTypePing, c.bufWriter, &n, &err)
-			c.sendMonitor.Update(int(n))
+			c.sent(n)
 			c.flush()
 		case <-c.pong:
 			c.Logger.Debug("Send Pong")
 			wire.WriteByte(packetTypePong, c.bufWriter, &n, &err)
-			c.sendMonitor.Update(int(n))
+			c.sent(n)
 			c.flush()
 		case <-c.quit:
 			break FOR_LOOP
@@ -339,10 +379,14 @@ FOR_LOOP:
 // Returns true if messages from channels were exhausted.
 // Blocks in accordance to .sendMonitor throttling.
 func (c *MConnection) sendSomeMsgPackets() bool {
-	// Block until .sendMonitor says we can write.
+	// Block until .sendMonitor, and the node-wide budget, say we can write.
 	// Once we're ready we send more than we asked for,
 	// but amortized it should even out.
-	c.sendMonitor.Limit(maxMsgPacketTotalSize, atomic.LoadInt64(&c.config.SendRate), true)
+	waited := waitForRate(c.sendMonitor, atomic.LoadInt64(&c.config.SendRate))
+	if c.config.sendBudget != nil {
+		waited += waitForRate(c.config.sendBudget, atomic.LoadInt64(&c.config.MaxSendRate))
+	}
+	atomic.AddInt64(&c.sendThrottled, int64(waited))
 
 	// Now send some msgPackets.
 	for i := 0; i < numBatchMsgPackets; i++ {
@@ -359,11 +403,18 @@ func (c *MConnection) sendMsgPacket() bool {
 	// The chosen channel will be the one whose recentlySent/priority is the least.
 	var leastRatio float32 = math.MaxFloat32
 	var leastChannel *Channel
+	now := time.Now()
+	throttled := false
 	for _, channel := range c.channels {
 		// If nothing to send, skip this channel
 		if !channel.isSendPending() {
 			continue
 		}
+		// If over its send rate, skip it for now
+		if channel.isSendThrottled(now) {
+			throttled = true
+			continue
+		}
 		// Get ratio, and keep track of lowest ratio.
 		ratio := float32(channel.recentlySent) / float32(channel.priority)
 		if ratio < leastRatio {
@@ -374,6 +425,9 @@ func (c *MConnection) sendMsgPacket() bool {
 
 	// Nothing to send?
 	if leastChannel == nil {
+		if throttled {
+			c.wakeSendAfter(throttledChannelWait)
+		}
 		return true
 	} else {
 		// c.Logger.Info("Found a msgPacket to send")
@@ -386,11 +440,25 @@ func (c *MConnection) sendMsgPacket() bool {
 		c.stopForError(err)
 		return true
 	}
-	c.sendMonitor.Update(int(n))
+	c.sent(n)
 	c.flushTimer.Set()
 	return false
 }
 
+// wakeSendAfter wakes up the sendRoutine after d, to send from throttled channels.
+func (c *MConnection) wakeSendAfter(d time.Duration) {
+	if !atomic.CompareAndSwapUint32(&c.sendWakeScheduled, 0, 1) {
+		return
+	}
+	time.AfterFunc(d, func() {
+		atomic.StoreUint32(&c.sendWakeScheduled, 0)
+		select {
+		case c.send <- struct{}{}:
+		default:
+		}
+	})
+}
+
 // recvRoutine reads msgPackets and reconstructs the message using the channels' "recving" buffer.
 // After a whole message has been assembled, it's pushed to onReceive().
 // Blocks depending on how the connection is throttled.
@@ -399,8 +467,12 @@ func (c *MConnection) recvRoutine() {
 
 FOR_LOOP:
 	for {
-		// Block until .recvMonitor says we can read.
-		c.recvMonitor.Limit(maxMsgPacketTotalSize, atomic.LoadInt64(&c.config.RecvRate), true)
+		// Block until .recvMonitor, and the node-wide budget, say we can read.
+		waited := waitForRate(c.recvMonitor, atomic.LoadInt64(&c.config.RecvRate))
+		if c.config.recvBudget != nil {
+			waited += waitForRate(c.config.recvBudget, atomic.LoadInt64(&c.config.MaxRecvRate))
+		}
+		atomic.AddInt64(&c.recvThrottled, int64(waited))
 
 		/*
 			// Peek into bufReader for debugging
@@ -421,7 +493,7 @@ FOR_LOOP:
 		var n int
 		var err error
 		pktType := wire.ReadByte(c.bufReader, &n, &err)
-		c.recvMonitor.Update(int(n))
+		c.received(n)
 		if err != nil {
 			if c.IsRunning() {
 				c.Logger.Error("Connection failed @ recvRoutine (reading byte)", "conn", c, "err", err)
@@ -442,7 +514,7 @@ FOR_LOOP:
 		case packetTypeMsg:
 			pkt, n, err := msgPacket{}, int(0), error(nil)
 			wire.ReadBinaryPtr(&pkt, c.bufReader, maxMsgPacketTotalSize, &n, &err)
-			c.recvMonitor.Update(int(n))
+			c.received(n)
 			if err != nil {
 				if c.IsRunning() {
 					c.Logger.Error("Connection failed @ recvRoutine", "conn", c, "err", err)
@@ -454,6 +526,7 @@ FOR_LOOP:
 			if !ok || channel == nil {
 				cmn.PanicQ(cmn.Fmt("Unknown channel %X", pkt.ChannelID))
 			}
+			channel.recvMonitor.Update(n)
 			msgBytes, err := channel.recvMsgPacket(pkt)
 			if err != nil {
 				if c.IsRunning() {
@@ -466,6 +539,11 @@ FOR_LOOP:
 				c.Logger.Debug("Received bytes", "chID", pkt.ChannelID, "msgBytes", msgBytes)
 				c.onReceive(pkt.ChannelID, msgBytes)
 			}
+			// Block until the channel is under its recv rate
+			if channel.desc.RecvRate > 0 {
+				waited := waitForRate(channel.recvMonitor, channel.desc.RecvRate)
+				atomic.AddInt64(&channel.recvThrottled, int64(waited))
+			}
 		default:
 			cmn.PanicSanity(cmn.Fmt("Unknown message type %X", pktType))
 		}
@@ -482,10 +560,22 @@ FOR_LOOP:
 	}
 }
 
+// waitForRate blocks until the monitor is under the rate, and returns how long it blocked.
+func waitForRate(m *flow.Monitor, rate int64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	start := time.Now()
+	m.Limit(maxMsgPacketTotalSize, rate, true)
+	return time.Since(start)
+}
+
 type ConnectionStatus struct {
-	SendMonitor flow.Status
-	RecvMonitor flow.Status
-	Channels    []ChannelStatus
+	SendMonitor   flow.Status
+	RecvMonitor   flow.Status
+	SendThrottled time.Duration // time spent waiting for the connection's and node's send rates
+	RecvThrottled time.Duration // time spent waiting for the connection's and node's recv rates
+	Channels      []ChannelStatus
 }
 
 type ChannelStatus struct {
@@ -494,12 +584,22 @@ type ChannelStatus struct {
 	SendQueueSize     int
 	Priority          int
 	RecentlySent      int64
+	SendRate          int64 // the channel's limits, 0 for none
+	RecvRate          int64
+	SendMonitor       flow.Status
+	RecvMonitor       flow.Status
+	SentMsgs          int64
+	RecvMsgs          int64
+	SendThrottled     time.Duration // time msgs spent waiting for the channel's send rate
+	RecvThrottled     time.Duration // time spent blocking the connection for the channel's recv rate
 }
 
 func (c *MConnection) Status() ConnectionStatus {
 	var status ConnectionStatus
 	status.SendMonitor = c.sendMonitor.Status()
 	status.RecvMonitor = c.recvMonitor.Status()
+	status.SendThrottled = time.Duration(atomic.LoadInt64(&c.sendThrottled))
+	status.RecvThrottled = time.Duration(atomic.LoadInt64(&c.recvThrottled))
 	status.Channels = make([]ChannelStatus, len(c.channels))
 	for i, channel := range c.channels {
 		status.Channels[i] = ChannelStatus{
@@ -508,6 +608,14 @@ func (c *MConnection) Status() ConnectionStatus {
 			SendQueueSize:     int(channel.sendQueueSize), // TODO use atomic
 			Priority:          channel.priority,
 			RecentlySent:      channel.recentlySent,
+			SendRate:          channel.desc.SendRate,
+			RecvRate:          channel.desc.RecvRate,
+			SendMonitor:       channel.sendMonitor.Status(),
+			RecvMonitor:       channel.recvMonitor.Status(),
+			SentMsgs:          atomic.LoadInt64(&channel.sentMsgs),
+			RecvMsgs:          atomic.LoadInt64(&channel.recvMsgs),
+			SendThrottled:     time.Duration(atomic.LoadInt64(&channel.sendThrottled)),
+			RecvThrottled:     time.Duration(atomic.LoadInt64(&channel.recvThrottled)),
 		}
 	}
 	return status
@@ -521,6 +629,10 @@ type ChannelDescriptor struct {
 	SendQueueCapacity   int
 	RecvBufferCapacity  int
 	RecvMessageCapacity int
+
+	// Limits on the channel's rates with each peer, in bytes/s. 0 means no limit
+	SendRate int64
+	RecvRate int64
 }
 
 func (chDesc *ChannelDescriptor) FillDefaults() {
@@ -547,6 +659,14 @@ type Channel struct {
 	sending       []byte
 	priority      int
 	recentlySent  int64 // exponential moving average
+
+	sendMonitor    *flow.Monitor
+	recvMonitor    *flow.Monitor
+	sentMsgs       int64     // atomic.
+	recvMsgs       int64     // atomic.
+	sendThrottled  int64     // atomic. ns
+	recvThrottled  int64     // atomic. ns
+	throttledSince time.Time // when the msg being sent was first held up by the send rate
 }
 
 func newChannel(conn *MConnection, desc *ChannelDescriptor) *Channel {
@@ -561,6 +681,9 @@ func newChannel(conn *MConnection, desc *ChannelDescriptor) *Channel {
 		sendQueue: make(chan []byte, desc.SendQueueCapacity),
 		recving:   make([]byte, 0, desc.RecvBufferCapacity),
 		priority:  desc.Priority,
+
+		sendMonitor: flow.New(0, 0),
+		recvMonitor: flow.New(0, 0),
 	}
 }
 
@@ -614,6 +737,26 @@ func (ch *Channel) isSendPending() bool {
 	return true
 }
 
+// Returns true if the channel has sent as much as its send rate allows for now.
+// Call after isSendPending(), as it counts the time a pending msg is held up.
+// Not goroutine-safe
+func (ch *Channel) isSendThrottled(now time.Time) bool {
+	if ch.desc.SendRate <= 0 {
+		return false
+	}
+	if ch.sendMonitor.Limit(maxMsgPacketTotalSize, ch.desc.SendRate, false) > 0 {
+		if !ch.throttledSince.IsZero() {
+			atomic.AddInt64(&ch.sendThrottled, int64(now.Sub(ch.throttledSince)))
+			ch.throttledSince = time.Time{}
+		}
+		return false
+	}
+	if ch.throttledSince.IsZero() {
+		ch.throttledSince = now
+	}
+	return true
+}
+
 // Creates a new msgPacket to send.
 // Not goroutine-safe
 func (ch *Channel) nextMsgPacket() msgPacket {
@@ -624,6 +767,7 @@ func (ch *Channel) nextMsgPacket() msgPacket {
 		packet.EOF = byte(0x01)
 		ch.sending = nil
 		atomic.AddInt32(&ch.sendQueueSize, -1) // decrement sendQueueSize
+		atomic.AddInt64(&ch.sentMsgs, 1)
 	} else {
 		packet.EOF = byte(0x00)
 		ch.sending = ch.sending[cmn.MinInt(maxMsgPacketPayloadSize, len(ch.sending)):]
@@ -640,6 +784,7 @@ func (ch *Channel) writeMsgPacketTo(w io.Writer) (n int, err error) {
 	wire.WriteBinary(packet, w, &n, &err)
 	if err == nil {
 		ch.recentlySent += int64(n)
+		ch.sendMonitor.Update(n)
 	}
 	return
 }
@@ -659,6 +804,7 @@ func (ch *Channel) recvMsgPacket(packet msgPacket) ([]byte, error) {
 		//   suggests this could be a memory leak, but we might as well keep the memory for the channel until it closes,
 		//	at which point the recving slice stops being used and should be garbage collected
 		ch.recving = ch.recving[:0] // make([]byte, 0, ch.desc.RecvBufferCapacity)
+		atomic.AddInt64(&ch.recvMsgs, 1)
 		return msgBytes, nil
 	}
 	return nil, nil
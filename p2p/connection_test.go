@@ -142,3 +142,72 @@ func TestMConnectionStopsAndReturnsError(t *testing.T) {
 		t.Fatal("Did not receive error in 500ms")
 	}
 }
+
+func TestMConnectionChannelSendRate(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	chDescs := []*p2p.ChannelDescriptor{
+		&p2p.ChannelDescriptor{ID: 0x01, Priority: 1, SendQueueCapacity: 10, SendRate: 2000},
+		&p2p.ChannelDescriptor{ID: 0x02, Priority: 1, SendQueueCapacity: 10},
+	}
+	receivedCh := make(chan byte, 20)
+	onReceive := func(chID byte, msgBytes []byte) {
+		receivedCh <- chID
+	}
+	onError := func(r interface{}) {}
+	mconn1 := p2p.NewMConnection(client, chDescs, onReceive, onError)
+	mconn1.SetLogger(log.TestingLogger())
+	_, err := mconn1.Start()
+	require.Nil(err)
+	defer mconn1.Stop()
+
+	mconn2 := p2p.NewMConnection(server, chDescs, func(byte, []byte) {}, onError)
+	mconn2.SetLogger(log.TestingLogger())
+	_, err = mconn2.Start()
+	require.Nil(err)
+	defer mconn2.Stop()
+
+	msg := string(make([]byte, 1000))
+	for i := 0; i < 10; i++ {
+		require.True(mconn2.TrySend(0x01, msg))
+	}
+	for i := 0; i < 10; i++ {
+		require.True(mconn2.TrySend(0x02, msg))
+	}
+
+	// the capped channel doesn't hold up the other
+	received := map[byte]int{}
+	for received[0x02] < 10 {
+		select {
+		case chID := <-receivedCh:
+			received[chID]++
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("Expected the uncapped channel's msgs, got %v", received)
+		}
+	}
+	assert.True(received[0x01] < 10, "Expected the capped channel to be throttled")
+
+	for received[0x01] < 10 {
+		select {
+		case chID := <-receivedCh:
+			received[chID]++
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected the capped channel's msgs, got %v", received)
+		}
+	}
+
+	status := mconn2.Status()
+	assert.EqualValues(10, status.Channels[0].SentMsgs)
+	assert.EqualValues(10, status.Channels[1].SentMsgs)
+	assert.True(status.Channels[0].SendThrottled > 0)
+	assert.Zero(status.Channels[1].SendThrottled)
+	assert.True(status.Channels[0].SendMonitor.Bytes >= 10000)
+
+	recvStatus := mconn1.Status()
+	assert.EqualValues(10, recvStatus.Channels[0].RecvMsgs)
+	assert.EqualValues(10, recvStatus.Channels[1].RecvMsgs)
+}
@@ -1,14 +1,18 @@
 package p2p
 
 import (
+	"encoding/hex"
+	"net"
 	"strings"
+	"sync"
 )
 
-// TODO Test
+// AddToIPRangeCounts counts the ip in each range it's in: every prefix of its parts,
+// including the whole ip. It returns the changed counts.
 func AddToIPRangeCounts(counts map[string]int, ip string) map[string]int {
 	changes := make(map[string]int)
 	ipParts := strings.Split(ip, ":")
-	for i := 1; i < len(ipParts); i++ {
+	for i := 1; i <= len(ipParts); i++ {
 		prefix := strings.Join(ipParts[:i], ":")
 		counts[prefix] += 1
 		changes[prefix] = counts[prefix]
@@ -16,14 +20,102 @@ func AddToIPRangeCounts(counts map[string]int, ip string) map[string]int {
 	return changes
 }
 
-// TODO Test
+// RemoveFromIPRangeCounts undoes AddToIPRangeCounts.
+func RemoveFromIPRangeCounts(counts map[string]int, ip string) {
+	ipParts := strings.Split(ip, ":")
+	for i := 1; i <= len(ipParts); i++ {
+		prefix := strings.Join(ipParts[:i], ":")
+		counts[prefix] -= 1
+		if counts[prefix] <= 0 {
+			delete(counts, prefix)
+		}
+	}
+}
+
+// CheckIPRangeCounts returns false if a count is over the limit for ranges
+// with its number of parts. Missing limits, and limits of 0, mean no limit.
 func CheckIPRangeCounts(counts map[string]int, limits []int) bool {
 	for prefix, count := range counts {
 		ipParts := strings.Split(prefix, ":")
 		numParts := len(ipParts)
+		if numParts >= len(limits) || limits[numParts] <= 0 {
+			continue
+		}
 		if limits[numParts] < count {
 			return false
 		}
 	}
 	return true
 }
+
+// ipRangeString returns the ip in the form the IP range counts use: four parts,
+// with IPv4 addresses split into bytes and IPv6 addresses into 32 bit words.
+// So the ranges are the same /8, /16, /24 and ip for IPv4,
+// and the same /32, /64, /96 and ip for IPv6.
+func ipRangeString(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return strings.Replace(ip4.String(), ".", ":", -1)
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ip.String()
+	}
+	parts := make([]string, 4)
+	for i := range parts {
+		parts[i] = hex.EncodeToString(ip16[4*i : 4*i+4])
+	}
+	return strings.Join(parts, ":")
+}
+
+//-----------------------------------------------------------------------------
+
+// ipRangeLimiter limits the number of peers from the same IP range.
+// See P2PConfig.MaxPeersPerIPRange.
+type ipRangeLimiter struct {
+	mtx    sync.Mutex
+	limits []int             // indexed by the number of parts in the range
+	counts map[string]int    // IP range -> number of peers in it
+	peers  map[string]string // peer key -> its IP in ipRangeString form
+}
+
+// newIPRangeLimiter returns a limiter with the limits for the /8, /16, /24
+// and whole IPv4 address ranges (/32, /64, /96 and whole IPv6 address).
+func newIPRangeLimiter(limits []int) *ipRangeLimiter {
+	return &ipRangeLimiter{
+		limits: append([]int{0}, limits...),
+		counts: make(map[string]int),
+		peers:  make(map[string]string),
+	}
+}
+
+// Add counts the peer's ip, or returns ErrSwitchIPRangeLimit
+// if that would take a range over its limit.
+// It returns ErrSwitchDuplicatePeer if the peer is already counted,
+// so only the caller that counted it removes it.
+func (l *ipRangeLimiter) Add(peerKey string, ip net.IP) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if _, ok := l.peers[peerKey]; ok {
+		return ErrSwitchDuplicatePeer
+	}
+	ipStr := ipRangeString(ip)
+	changes := AddToIPRangeCounts(l.counts, ipStr)
+	if !CheckIPRangeCounts(changes, l.limits) {
+		RemoveFromIPRangeCounts(l.counts, ipStr)
+		return ErrSwitchIPRangeLimit
+	}
+	l.peers[peerKey] = ipStr
+	return nil
+}
+
+// Remove stops counting the peer's ip. It is safe to call more than once.
+func (l *ipRangeLimiter) Remove(peerKey string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	ipStr, ok := l.peers[peerKey]
+	if !ok {
+		return
+	}
+	RemoveFromIPRangeCounts(l.counts, ipStr)
+	delete(l.peers, peerKey)
+}
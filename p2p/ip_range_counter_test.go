@@ -0,0 +1,51 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRangeCounts(t *testing.T) {
+	assert := assert.New(t)
+
+	counts := make(map[string]int)
+	AddToIPRangeCounts(counts, "1:2:3:4")
+	changes := AddToIPRangeCounts(counts, "1:2:3:5")
+	assert.Equal(map[string]int{"1": 2, "1:2": 2, "1:2:3": 2, "1:2:3:5": 1}, changes)
+
+	assert.True(CheckIPRangeCounts(changes, []int{0, 2, 2, 2, 1}))
+	assert.False(CheckIPRangeCounts(changes, []int{0, 2, 2, 1, 1}))
+	assert.True(CheckIPRangeCounts(changes, []int{0, 0, 0, 0}), "0 means no limit")
+	assert.True(CheckIPRangeCounts(changes, nil))
+
+	RemoveFromIPRangeCounts(counts, "1:2:3:5")
+	assert.Equal(map[string]int{"1": 1, "1:2": 1, "1:2:3": 1, "1:2:3:4": 1}, counts)
+}
+
+func TestIPRangeString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("10:0:1:2", ipRangeString(net.ParseIP("10.0.1.2")))
+	assert.Equal("20010db8:00000000:00000000:00000001", ipRangeString(net.ParseIP("2001:db8::1")))
+}
+
+func TestIPRangeLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	// two peers per IP, three per /24
+	l := newIPRangeLimiter([]int{0, 0, 3, 2})
+
+	assert.Nil(l.Add("a", net.ParseIP("10.0.1.2")))
+	assert.Nil(l.Add("b", net.ParseIP("10.0.1.2")), "peers can share an IP")
+	assert.Equal(ErrSwitchDuplicatePeer, l.Add("b", net.ParseIP("10.0.1.2")), "the same peer is counted once")
+	assert.Equal(ErrSwitchIPRangeLimit, l.Add("c", net.ParseIP("10.0.1.2")))
+	assert.Nil(l.Add("c", net.ParseIP("10.0.1.3")))
+	assert.Equal(ErrSwitchIPRangeLimit, l.Add("d", net.ParseIP("10.0.1.4")))
+	assert.Nil(l.Add("d", net.ParseIP("10.0.2.4")))
+
+	l.Remove("a")
+	l.Remove("a")
+	assert.Nil(l.Add("e", net.ParseIP("10.0.1.4")))
+}
@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	crypto "github.com/tendermint/go-crypto"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+// ID is a node's stable identity: the hex encoded address of its node key's PubKey.
+type ID string
+
+// IDByteLength is the length of the address an ID encodes.
+const IDByteLength = 20
+
+// PubKeyToID returns the ID of the node with the given PubKey.
+func PubKeyToID(pubKey crypto.PubKey) ID {
+	return ID(hex.EncodeToString(pubKey.Address()))
+}
+
+// NodeKey is the persistent key a node authenticates its p2p connections with.
+// It is kept in the node_key.json file, so the node's ID survives restarts.
+type NodeKey struct {
+	PrivKey crypto.PrivKey `json:"priv_key"`
+}
+
+// ID returns the node's ID.
+func (nodeKey *NodeKey) ID() ID {
+	return PubKeyToID(nodeKey.PubKey())
+}
+
+// PubKey returns the node's PubKey.
+func (nodeKey *NodeKey) PubKey() crypto.PubKey {
+	return nodeKey.PrivKey.PubKey()
+}
+
+// PrivKeyEd25519 returns the key for the switch, which only supports ed25519.
+func (nodeKey *NodeKey) PrivKeyEd25519() (crypto.PrivKeyEd25519, error) {
+	privKey, ok := nodeKey.PrivKey.Unwrap().(crypto.PrivKeyEd25519)
+	if !ok {
+		return crypto.PrivKeyEd25519{}, errors.Errorf("Node key must be ed25519, got %T", nodeKey.PrivKey.Unwrap())
+	}
+	return privKey, nil
+}
+
+// LoadOrGenNodeKey loads the node key in the file,
+// or generates a new one and saves it to the file if there is none.
+func LoadOrGenNodeKey(filePath string) (*NodeKey, error) {
+	if cmn.FileExists(filePath) {
+		return LoadNodeKey(filePath)
+	}
+	return GenNodeKey(filePath)
+}
+
+// LoadNodeKey loads the node key in the file.
+func LoadNodeKey(filePath string) (*NodeKey, error) {
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	nodeKey := new(NodeKey)
+	if err := json.Unmarshal(jsonBytes, nodeKey); err != nil {
+		return nil, errors.Wrapf(err, "Error reading NodeKey from %v", filePath)
+	}
+	if nodeKey.PrivKey.Empty() {
+		return nil, errors.Errorf("No priv_key in NodeKey file %v", filePath)
+	}
+	return nodeKey, nil
+}
+
+// GenNodeKey generates a new ed25519 node key and saves it to the file.
+func GenNodeKey(filePath string) (*NodeKey, error) {
+	nodeKey := &NodeKey{
+		PrivKey: crypto.GenPrivKeyEd25519().Wrap(),
+	}
+	jsonBytes, err := json.Marshal(nodeKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filePath, jsonBytes, 0600); err != nil {
+		return nil, err
+	}
+	return nodeKey, nil
+}
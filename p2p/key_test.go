@@ -0,0 +1,48 @@
+package p2p
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	crypto "github.com/tendermint/go-crypto"
+)
+
+func TestLoadOrGenNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node_key_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "node_key.json")
+
+	nodeKey, err := LoadOrGenNodeKey(filePath)
+	require.Nil(t, err)
+	assert.Len(t, nodeKey.ID(), 40)
+
+	// the node keeps its ID
+	nodeKey2, err := LoadOrGenNodeKey(filePath)
+	require.Nil(t, err)
+	assert.Equal(t, nodeKey.ID(), nodeKey2.ID())
+
+	privKey, err := nodeKey2.PrivKeyEd25519()
+	require.Nil(t, err)
+	nodeInfo := &NodeInfo{PubKey: privKey.PubKey().Unwrap().(crypto.PubKeyEd25519)}
+	assert.Equal(t, nodeKey.ID(), nodeInfo.ID())
+}
+
+func TestLoadNodeKeyErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node_key_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "node_key.json")
+
+	_, err = LoadNodeKey(filePath)
+	assert.NotNil(t, err, "missing file")
+
+	require.Nil(t, ioutil.WriteFile(filePath, []byte(`{}`), 0600))
+	_, err = LoadNodeKey(filePath)
+	assert.NotNil(t, err, "no priv_key")
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -48,7 +49,17 @@ func splitHostPort(addr string) (host string, port int) {
 }
 
 // skipUPNP: If true, does not try getUPNPExternalAddress()
+// It panics if it can't listen on the address; see TCPTransport.Listen for
+// a version that returns the error.
 func NewDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log.Logger) Listener {
+	l, err := newDefaultListener(protocol, lAddr, skipUPNP, logger)
+	if err != nil {
+		cmn.PanicCrisis(err)
+	}
+	return l
+}
+
+func newDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log.Logger) (*DefaultListener, error) {
 	// Local listen IP & port
 	lAddrIP, lAddrPort := splitHostPort(lAddr)
 
@@ -64,7 +75,7 @@ func NewDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log
 		}
 	}
 	if err != nil {
-		cmn.PanicCrisis(err)
+		return nil, err
 	}
 	// Actual listener local IP & port
 	listenerIP, listenerPort := splitHostPort(listener.Addr().String())
@@ -74,7 +85,8 @@ func NewDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log
 	var intAddr *NetAddress
 	intAddr, err = NewNetAddressString(lAddr)
 	if err != nil {
-		cmn.PanicCrisis(err)
+		listener.Close()
+		return nil, err
 	}
 
 	// Determine external address...
@@ -90,7 +102,8 @@ func NewDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log
 		extAddr = getNaiveExternalAddress(listenerPort)
 	}
 	if extAddr == nil {
-		cmn.PanicCrisis("Could not determine external address!")
+		listener.Close()
+		return nil, errors.New("Could not determine external address!")
 	}
 
 	dl := &DefaultListener{
@@ -101,7 +114,7 @@ func NewDefaultListener(protocol string, lAddr string, skipUPNP bool, logger log
 	}
 	dl.BaseService = *cmn.NewBaseService(logger, "DefaultListener", dl)
 	dl.Start() // Started upon construction
-	return dl
+	return dl, nil
 }
 
 func (l *DefaultListener) OnStart() error {
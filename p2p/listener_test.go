@@ -40,3 +40,20 @@ func TestListener(t *testing.T) {
 	// Close the server, no longer needed.
 	l.Stop()
 }
+
+func TestTCPTransportListenErr(t *testing.T) {
+	addr, err := NewNetAddressString("127.0.0.1:8002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := (TCPTransport{}).Listen(addr)
+	if err != nil {
+		t.Fatalf("Could not listen on %v: %v", addr, err)
+	}
+	defer l.Stop()
+
+	// the address is taken, so this one fails rather than panicking
+	if _, err := (TCPTransport{}).Listen(addr); err == nil {
+		t.Fatalf("Expected an error listening on %v twice", addr)
+	}
+}
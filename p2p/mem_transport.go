@@ -0,0 +1,436 @@
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	crypto "github.com/tendermint/go-crypto"
+)
+
+const (
+	defaultMemRetransmitTimeout = 200 * time.Millisecond
+	memConnQueueSize            = 1024
+
+	// ports given to the dialing ends of connections, and to listeners on port 0
+	memFirstPort = 49152
+	memLastPort  = 65535
+)
+
+// MemTransport is a Transport that connects switches in memory, with no sockets.
+// All the switches of a simulated network share one MemTransport,
+// and listen on and dial any addresses they like on it.
+//
+// Every write arrives Latency later. The connections are streams, like TCP,
+// so a lost write isn't dropped: it's retransmitted RetransmitTimeout later,
+// and may be lost again, holding up the writes behind it.
+// With a LossRate of 1, nothing arrives at all.
+type MemTransport struct {
+	Latency           time.Duration
+	LossRate          float64 // between 0 and 1
+	RetransmitTimeout time.Duration
+
+	mtx       sync.Mutex
+	listeners map[string]*memListener // dial string -> listener
+	nextPort  uint16
+}
+
+var _ Transport = (*MemTransport)(nil)
+
+// NewMemTransport returns a MemTransport with no latency or loss.
+func NewMemTransport() *MemTransport {
+	return &MemTransport{
+		RetransmitTimeout: defaultMemRetransmitTimeout,
+		listeners:         make(map[string]*memListener),
+		nextPort:          memFirstPort,
+	}
+}
+
+// Dial implements Transport. It fails if nothing listens on the address,
+// or its listener has too many connections waiting to be accepted.
+func (t *MemTransport) Dial(addr *NetAddress, timeout time.Duration) (net.Conn, error) {
+	t.mtx.Lock()
+	l, ok := t.listeners[addr.DialString()]
+	localAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(t.port())}
+	t.mtx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("dial %v: connection refused", addr.DialString())
+	}
+
+	remoteAddr := &net.TCPAddr{IP: addr.IP, Port: int(addr.Port)}
+	ourPipe, theirPipe := newMemPipe(), newMemPipe()
+	ourConn := t.newConn(ourPipe, theirPipe, localAddr, remoteAddr)
+	theirConn := t.newConn(theirPipe, ourPipe, remoteAddr, localAddr)
+	if !l.accept(theirConn) {
+		ourConn.Close()
+		theirConn.Close()
+		return nil, fmt.Errorf("dial %v: connection refused", addr.DialString())
+	}
+	return ourConn, nil
+}
+
+// Listen implements Transport. On port 0, the listener gets a free port.
+func (t *MemTransport) Listen(addr *NetAddress) (Listener, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if addr.Port == 0 {
+		addr = NewNetAddressIPPort(addr.IP, t.port())
+	}
+	if _, ok := t.listeners[addr.DialString()]; ok {
+		return nil, fmt.Errorf("listen %v: address already in use", addr.DialString())
+	}
+	l := &memListener{
+		transport:   t,
+		addr:        addr,
+		connections: make(chan net.Conn, numBufferedConnections),
+	}
+	t.listeners[addr.DialString()] = l
+	return l, nil
+}
+
+// Upgrade implements Transport.
+func (t *MemTransport) Upgrade(conn net.Conn, ourPrivKey crypto.PrivKeyEd25519, timeout time.Duration) (*SecretConnection, error) {
+	return upgradeConn(conn, ourPrivKey, timeout)
+}
+
+// Handshake implements Transport.
+func (t *MemTransport) Handshake(conn net.Conn, ourNodeInfo *NodeInfo, timeout time.Duration) (*NodeInfo, error) {
+	return handshakeConn(conn, ourNodeInfo, timeout)
+}
+
+// port returns the next port to give out.
+// CONTRACT: t.mtx is held.
+func (t *MemTransport) port() uint16 {
+	port := t.nextPort
+	if t.nextPort == memLastPort {
+		t.nextPort = memFirstPort
+	} else {
+		t.nextPort++
+	}
+	return port
+}
+
+func (t *MemTransport) removeListener(l *memListener) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.listeners[l.addr.DialString()] == l {
+		delete(t.listeners, l.addr.DialString())
+	}
+}
+
+func (t *MemTransport) newConn(in, out *memPipe, localAddr, remoteAddr net.Addr) *memConn {
+	mc := &memConn{
+		in:         in,
+		out:        out,
+		transport:  t,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		delayed:    t.Latency > 0 || t.LossRate > 0,
+		closed:     make(chan struct{}),
+	}
+	if mc.delayed {
+		mc.queue = make(chan memWrite, memConnQueueSize)
+		go mc.deliverRoutine()
+	}
+	return mc
+}
+
+//-----------------------------------------------------------------------------
+
+// memListener implements Listener for a MemTransport.
+type memListener struct {
+	transport   *MemTransport
+	addr        *NetAddress
+	mtx         sync.Mutex
+	stopped     bool
+	connections chan net.Conn
+}
+
+// accept passes on the conn, and returns false if the listener is stopped or full.
+func (l *memListener) accept(conn net.Conn) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.stopped {
+		return false
+	}
+	select {
+	case l.connections <- conn:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *memListener) Connections() <-chan net.Conn {
+	return l.connections
+}
+
+func (l *memListener) InternalAddress() *NetAddress {
+	return l.addr
+}
+
+func (l *memListener) ExternalAddress() *NetAddress {
+	return l.addr
+}
+
+func (l *memListener) String() string {
+	return fmt.Sprintf("MemListener(@%v)", l.addr)
+}
+
+// Stop stops accepting connections, and closes Connections().
+// It returns false if the listener was already stopped.
+func (l *memListener) Stop() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.stopped {
+		return false
+	}
+	l.stopped = true
+	l.transport.removeListener(l)
+	close(l.connections)
+	return true
+}
+
+//-----------------------------------------------------------------------------
+
+// errMemTimeout is returned by memConn reads and writes past their deadlines.
+var errMemTimeout net.Error = memTimeoutError{}
+
+type memTimeoutError struct{}
+
+func (memTimeoutError) Error() string   { return "i/o timeout" }
+func (memTimeoutError) Timeout() bool   { return true }
+func (memTimeoutError) Temporary() bool { return true }
+
+// memPipe is one direction of a MemTransport connection: an unbounded buffer
+// written by one end and read by the other. Unlike net.Pipe, it lets memConn
+// implement deadlines, which net.Pipe ignores before Go 1.10.
+type memPipe struct {
+	mtx    sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+	ready  chan struct{} // signalled when there's something new to read
+}
+
+func newMemPipe() *memPipe {
+	return &memPipe{ready: make(chan struct{}, 1)}
+}
+
+func (p *memPipe) write(b []byte) error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return io.ErrClosedPipe
+	}
+	p.buf.Write(b)
+	p.mtx.Unlock()
+	p.signal()
+	return nil
+}
+
+// read reads what's buffered. It returns false if there's nothing to read yet.
+func (p *memPipe) read(b []byte) (int, bool, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.buf.Len() > 0 {
+		n, err := p.buf.Read(b)
+		return n, true, err
+	}
+	if p.closed {
+		return 0, true, io.EOF
+	}
+	return 0, false, nil
+}
+
+func (p *memPipe) close() {
+	p.mtx.Lock()
+	p.closed = true
+	p.mtx.Unlock()
+	p.signal()
+}
+
+// signal wakes up the reader, if it's waiting.
+func (p *memPipe) signal() {
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// memWrite is a write on a delayed memConn, to be delivered at the given time.
+type memWrite struct {
+	data []byte
+	at   time.Time
+}
+
+// memConn is one end of a MemTransport connection. It reads from one memPipe
+// and writes to the other. Without latency or loss its writes go straight
+// to the pipe; with them, they're queued and delivered by deliverRoutine.
+// Writes never block, so the write deadline only fails writes made after it.
+type memConn struct {
+	in  *memPipe
+	out *memPipe
+
+	transport  *MemTransport
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	delayed    bool
+
+	mtx           sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	queue     chan memWrite
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.Conn = (*memConn)(nil)
+
+func (c *memConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.mtx.Lock()
+	c.readDeadline = t
+	c.mtx.Unlock()
+	c.in.signal() // a waiting Read picks up the new deadline
+	return nil
+}
+
+func (c *memConn) SetWriteDeadline(t time.Time) error {
+	c.mtx.Lock()
+	c.writeDeadline = t
+	c.mtx.Unlock()
+	return nil
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for {
+		select {
+		case <-c.closed:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+		if n, ok, err := c.in.read(b); ok {
+			return n, err
+		}
+
+		c.mtx.Lock()
+		deadline := c.readDeadline
+		c.mtx.Unlock()
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := deadline.Sub(time.Now())
+			if d <= 0 {
+				return 0, errMemTimeout
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		select {
+		case <-c.in.ready:
+		case <-timeout:
+			return 0, errMemTimeout
+		case <-c.closed:
+			return 0, io.ErrClosedPipe
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	c.mtx.Lock()
+	deadline := c.writeDeadline
+	c.mtx.Unlock()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, errMemTimeout
+	}
+
+	if !c.delayed {
+		if err := c.out.write(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	t := c.transport
+	if t.LossRate >= 1 {
+		return len(b), nil // lost, and every retransmission too
+	}
+	at := time.Now().Add(t.Latency)
+	for rand.Float64() < t.LossRate {
+		at = at.Add(t.RetransmitTimeout)
+	}
+
+	data := make([]byte, len(b))
+	copy(data, b)
+	select {
+	case c.queue <- memWrite{data, at}:
+		return len(b), nil
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// Close closes both directions: the other end reads io.EOF once it has read
+// what was delivered, and its writes fail.
+func (c *memConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.in.close()
+		c.out.close()
+	})
+	return nil
+}
+
+// deliverRoutine writes the queued writes to the pipe, in order, each no
+// earlier than it's due.
+func (c *memConn) deliverRoutine() {
+	for {
+		select {
+		case w := <-c.queue:
+			if d := w.at.Sub(time.Now()); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-c.closed:
+					return
+				}
+			}
+			if err := c.out.write(w.data); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
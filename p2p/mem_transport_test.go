@@ -0,0 +1,163 @@
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemTransportSwitches(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	transport := NewMemTransport()
+	transport.Latency = 10 * time.Millisecond
+
+	s1 := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	s1.SetTransport(transport)
+	s2 := makeSwitch(config, 2, "testing", "123.123.123", initSwitchFunc)
+	s2.SetTransport(transport)
+
+	addr, err := NewNetAddressString("10.0.0.2:46656")
+	require.Nil(err)
+	l, err := transport.Listen(addr)
+	require.Nil(err)
+	s2.AddListener(l)
+
+	// the address is taken
+	_, err = transport.Listen(addr)
+	assert.NotNil(err)
+
+	require.Nil(StartSwitches([]*Switch{s1, s2}))
+	defer s1.Stop()
+	defer s2.Stop()
+
+	// the handshakes take a few round trips
+	addr.ID = PubKeyToID(s2.NodeInfo().PubKey.Wrap())
+	start := time.Now()
+	_, err = s1.DialPeerWithAddress(addr, false)
+	require.Nil(err)
+	assert.True(time.Since(start) >= 2*transport.Latency)
+
+	for i := 0; i < 100 && s2.Peers().Size() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(1, s2.Peers().Size())
+
+	s1.Broadcast(byte(0x00), "hello")
+	var msgs []PeerMessage
+	for i := 0; i < 100 && len(msgs) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		msgs = s2.Reactor("foo").(*TestReactor).getMsgs(byte(0x00))
+	}
+	assert.Equal(1, len(msgs))
+
+	// nothing listens on this one
+	other, err := NewNetAddressString("10.0.0.3:46656")
+	require.Nil(err)
+	_, err = s1.DialPeerWithAddress(other, false)
+	assert.NotNil(err)
+}
+
+// dialMemTransport returns the two ends of a connection on the transport.
+func dialMemTransport(t *testing.T, transport *MemTransport) (net.Conn, net.Conn) {
+	addr, err := NewNetAddressString("10.0.0.1:46656")
+	require.Nil(t, err)
+	l, err := transport.Listen(addr)
+	require.Nil(t, err)
+	defer l.Stop()
+
+	connOut, err := transport.Dial(addr, time.Second)
+	require.Nil(t, err)
+	connIn := <-l.Connections()
+	return connOut, connIn
+}
+
+func TestMemTransportLoss(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	transport := NewMemTransport()
+	transport.LossRate = 0.5
+	transport.RetransmitTimeout = 5 * time.Millisecond
+	connOut, connIn := dialMemTransport(t, transport)
+	defer connOut.Close()
+	defer connIn.Close()
+
+	// lost writes are retransmitted, and everything arrives in order
+	var msg []byte
+	for i := 0; i < 100; i++ {
+		msg = append(msg, byte(i))
+	}
+	go func() {
+		for _, b := range msg {
+			connOut.Write([]byte{b})
+		}
+	}()
+	received := make([]byte, len(msg))
+	connIn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := io.ReadFull(connIn, received)
+	require.Nil(err)
+	assert.True(bytes.Equal(msg, received))
+
+	// with a loss rate of 1, nothing arrives
+	transport = NewMemTransport()
+	transport.LossRate = 1
+	connOut, connIn = dialMemTransport(t, transport)
+	defer connOut.Close()
+	defer connIn.Close()
+
+	_, err = connOut.Write([]byte("hi!"))
+	require.Nil(err)
+	connIn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = connIn.Read(make([]byte, 3))
+	require.NotNil(err)
+	netErr, ok := err.(net.Error)
+	assert.True(ok && netErr.Timeout())
+}
+
+func TestMemTransportDeadlines(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	connOut, connIn := dialMemTransport(t, NewMemTransport())
+	defer connOut.Close()
+
+	// a read blocked on nothing times out, even if the deadline is set
+	// after it started waiting
+	done := make(chan error)
+	go func() {
+		_, err := connIn.Read(make([]byte, 1))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	connIn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	select {
+	case err := <-done:
+		netErr, ok := err.(net.Error)
+		assert.True(ok && netErr.Timeout())
+	case <-time.After(time.Second):
+		t.Fatal("Read didn't time out")
+	}
+
+	// clearing the deadline lets reads through again
+	connIn.SetReadDeadline(time.Time{})
+	_, err := connOut.Write([]byte("hi!"))
+	require.Nil(err)
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(connIn, buf)
+	require.Nil(err)
+	assert.Equal("hi!", string(buf))
+
+	// writes past the deadline fail
+	connOut.SetWriteDeadline(time.Now().Add(-time.Second))
+	_, err = connOut.Write([]byte("hi!"))
+	assert.NotNil(err)
+
+	// the other end reads EOF once we close
+	connIn.Close()
+	_, err = connOut.Read(buf)
+	assert.Equal(io.EOF, err)
+}
@@ -5,23 +5,33 @@
 package p2p
 
 import (
+	"encoding/hex"
 	"errors"
 	"flag"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	cmn "github.com/tendermint/tmlibs/common"
 )
 
 // NetAddress defines information about a peer on the network
-// including its IP address, and port.
+// including its ID, IP address, and port.
+// An address with an ID is written "ID@IP:Port", and dialing it fails
+// unless the peer authenticates with the node key of that ID.
 type NetAddress struct {
+	ID   ID
 	IP   net.IP
 	Port uint16
 	str  string
 }
 
+// IDAddressString returns id@hostPort.
+func IDAddressString(id ID, hostPort string) string {
+	return cmn.Fmt("%s@%s", id, hostPort)
+}
+
 // NewNetAddress returns a new NetAddress using the provided TCP
 // address. When testing, other net.Addr (except TCP) will result in
 // using 0.0.0.0:0. When normal run, other net.Addr (except TCP) will
@@ -42,9 +52,19 @@ func NewNetAddress(addr net.Addr) *NetAddress {
 }
 
 // NewNetAddressString returns a new NetAddress using the provided
-// address in the form of "IP:Port". Also resolves the host if host
-// is not an IP.
+// address in the form of "ID@IP:Port", where the "ID@" is optional.
+// Also resolves the host if host is not an IP.
 func NewNetAddressString(addr string) (*NetAddress, error) {
+	var id ID
+	if spl := strings.Split(addr, "@"); len(spl) > 1 {
+		if len(spl) > 2 {
+			return nil, errors.New(cmn.Fmt("Address %s has more than one @", addr))
+		}
+		id, addr = ID(spl[0]), spl[1]
+		if err := validateID(id); err != nil {
+			return nil, err
+		}
+	}
 
 	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -68,9 +88,22 @@ func NewNetAddressString(addr string) (*NetAddress, error) {
 	}
 
 	na := NewNetAddressIPPort(ip, uint16(port))
+	na.ID = id
 	return na, nil
 }
 
+// validateID returns an error if the ID is not the hex encoded address of a PubKey.
+func validateID(id ID) error {
+	bz, err := hex.DecodeString(string(id))
+	if err != nil {
+		return errors.New(cmn.Fmt("Invalid node ID %s: %v", id, err))
+	}
+	if len(bz) != IDByteLength {
+		return errors.New(cmn.Fmt("Invalid node ID %s: got %d bytes, expected %d", id, len(bz), IDByteLength))
+	}
+	return nil
+}
+
 // NewNetAddressStrings returns an array of NetAddress'es build using
 // the provided strings.
 func NewNetAddressStrings(addrs []string) ([]*NetAddress, error) {
@@ -91,10 +124,6 @@ func NewNetAddressIPPort(ip net.IP, port uint16) *NetAddress {
 	na := &NetAddress{
 		IP:   ip,
 		Port: port,
-		str: net.JoinHostPort(
-			ip.String(),
-			strconv.FormatUint(uint64(port), 10),
-		),
 	}
 	return na
 }
@@ -117,20 +146,29 @@ func (na *NetAddress) Less(other interface{}) bool {
 	return false
 }
 
-// String representation.
+// String representation: "ID@IP:Port", or "IP:Port" if there is no ID.
 func (na *NetAddress) String() string {
 	if na.str == "" {
-		na.str = net.JoinHostPort(
-			na.IP.String(),
-			strconv.FormatUint(uint64(na.Port), 10),
-		)
+		addrStr := na.DialString()
+		if na.ID != "" {
+			addrStr = IDAddressString(na.ID, addrStr)
+		}
+		na.str = addrStr
 	}
 	return na.str
 }
 
+// DialString returns "IP:Port", without the ID.
+func (na *NetAddress) DialString() string {
+	return net.JoinHostPort(
+		na.IP.String(),
+		strconv.FormatUint(uint64(na.Port), 10),
+	)
+}
+
 // Dial calls net.Dial on the address.
 func (na *NetAddress) Dial() (net.Conn, error) {
-	conn, err := net.Dial("tcp", na.String())
+	conn, err := net.Dial("tcp", na.DialString())
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +177,7 @@ func (na *NetAddress) Dial() (net.Conn, error) {
 
 // DialTimeout calls net.DialTimeout on the address.
 func (na *NetAddress) DialTimeout(timeout time.Duration) (net.Conn, error) {
-	conn, err := net.DialTimeout("tcp", na.String(), timeout)
+	conn, err := net.DialTimeout("tcp", na.DialString(), timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +36,11 @@ func TestNewNetAddressString(t *testing.T) {
 		{"a:8080", false},
 		{"8082", false},
 		{"127.0.0:8080000", false},
+		{"deadbeefdeadbeefdeadbeefdeadbeefdeadbeef@127.0.0.1:8080", true},
+		{"deadbeef@127.0.0.1:8080", false},
+		{"xxxxbeefdeadbeefdeadbeefdeadbeefdeadbeef@127.0.0.1:8080", false},
+		{"deadbeefdeadbeefdeadbeefdeadbeefdeadbeef@deadbeefdeadbeefdeadbeefdeadbeefdeadbeef@127.0.0.1:8080", false},
+		{"deadbeefdeadbeefdeadbeefdeadbeefdeadbeef@127.0.0.1", false},
 	}
 
 	for _, t := range tests {
@@ -50,6 +55,18 @@ func TestNewNetAddressString(t *testing.T) {
 	}
 }
 
+func TestNetAddressID(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	id := ID("deadbeefdeadbeefdeadbeefdeadbeefdeadbeef")
+	addr, err := NewNetAddressString(IDAddressString(id, "127.0.0.1:8080"))
+	require.Nil(err)
+
+	assert.Equal(id, addr.ID)
+	assert.Equal("127.0.0.1:8080", addr.DialString())
+	assert.Equal(string(id)+"@127.0.0.1:8080", addr.String())
+}
+
 func TestNewNetAddressStrings(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 	addrs, err := NewNetAddressStrings([]string{"127.0.0.1:8080", "127.0.0.2:8080"})
@@ -23,13 +23,18 @@ type Peer struct {
 	outbound bool
 
 	conn  net.Conn     // source connection
-	mconn *MConnection // multiplex connection
+	mconn *MConnection // multiplex connection, set by the handshake
+
+	// for creating mconn, with the channels both we and the peer support
+	reactorsByCh map[byte]Reactor
+	chDescs      []*ChannelDescriptor
+	onPeerError  func(*Peer, interface{})
 
 	persistent bool
 	config     *PeerConfig
 
 	*NodeInfo
-	Key  string
+	Key  string    // the peer's ID, set by the handshake
 	Data *cmn.CMap // User data.
 }
 
@@ -45,6 +50,8 @@ type PeerConfig struct {
 
 	Fuzz       bool            `mapstructure:"fuzz"` // fuzz connection (for testing)
 	FuzzConfig *FuzzConnConfig `mapstructure:"fuzz_config"`
+
+	Transport Transport `mapstructure:"-"` // dials, accepts and upgrades connections
 }
 
 // DefaultPeerConfig returns the default config.
@@ -56,6 +63,7 @@ func DefaultPeerConfig() *PeerConfig {
 		MConfig:          DefaultMConnConfig(),
 		Fuzz:             false,
 		FuzzConfig:       DefaultFuzzConnConfig(),
+		Transport:        TCPTransport{},
 	}
 }
 
@@ -72,6 +80,18 @@ func newOutboundPeer(addr *NetAddress, reactorsByCh map[byte]Reactor, chDescs []
 		conn.Close()
 		return nil, err
 	}
+
+	// Check that the peer we dialed is the one we meant to dial
+	if addr.ID != "" {
+		if !config.AuthEnc {
+			conn.Close()
+			return nil, errors.Errorf("Cannot authenticate peer %v without auth_enc", addr)
+		}
+		if id := PubKeyToID(peer.PubKey().Wrap()); id != addr.ID {
+			conn.Close()
+			return nil, errors.Errorf("Peer %v authenticated as %v", addr, id)
+		}
+	}
 	return peer, nil
 }
 
@@ -94,25 +114,24 @@ func newPeerFromConnAndConfig(rawConn net.Conn, outbound bool, reactorsByCh map[
 
 	// Encrypt connection
 	if config.AuthEnc {
-		conn.SetDeadline(time.Now().Add(config.HandshakeTimeout * time.Second))
-
-		var err error
-		conn, err = MakeSecretConnection(conn, ourNodePrivKey)
+		sconn, err := config.Transport.Upgrade(conn, ourNodePrivKey, config.HandshakeTimeout*time.Second)
 		if err != nil {
-			return nil, errors.Wrap(err, "Error creating peer")
+			return nil, err
 		}
+		conn = sconn
 	}
 
-	// Key and NodeInfo are set after Handshake
+	// Key, NodeInfo and mconn are set after Handshake
 	p := &Peer{
-		outbound: outbound,
-		conn:     conn,
-		config:   config,
-		Data:     cmn.NewCMap(),
+		outbound:     outbound,
+		conn:         conn,
+		config:       config,
+		reactorsByCh: reactorsByCh,
+		chDescs:      chDescs,
+		onPeerError:  onPeerError,
+		Data:         cmn.NewCMap(),
 	}
 
-	p.mconn = createMConnection(conn, p, reactorsByCh, chDescs, onPeerError, config.MConfig)
-
 	p.BaseService = *cmn.NewBaseService(nil, "Peer", p)
 
 	return p, nil
@@ -137,31 +156,15 @@ func (p *Peer) IsPersistent() bool {
 	return p.persistent
 }
 
-// HandshakeTimeout performs a handshake between a given node and the peer.
+// HandshakeTimeout performs a handshake between a given node and the peer,
+// and opens the channels both support.
 // NOTE: blocking
 func (p *Peer) HandshakeTimeout(ourNodeInfo *NodeInfo, timeout time.Duration) error {
-	// Set deadline for handshake so we don't block forever on conn.ReadFull
-	p.conn.SetDeadline(time.Now().Add(timeout))
-
-	var peerNodeInfo = new(NodeInfo)
-	var err1 error
-	var err2 error
-	cmn.Parallel(
-		func() {
-			var n int
-			wire.WriteBinary(ourNodeInfo, p.conn, &n, &err1)
-		},
-		func() {
-			var n int
-			wire.ReadBinary(peerNodeInfo, p.conn, maxNodeInfoSize, &n, &err2)
-			p.Logger.Info("Peer handshake", "peerNodeInfo", peerNodeInfo)
-		})
-	if err1 != nil {
-		return errors.Wrap(err1, "Error during handshake/write")
-	}
-	if err2 != nil {
-		return errors.Wrap(err2, "Error during handshake/read")
+	peerNodeInfo, err := p.config.Transport.Handshake(p.conn, ourNodeInfo, timeout)
+	if err != nil {
+		return err
 	}
+	p.Logger.Info("Peer handshake", "peerNodeInfo", peerNodeInfo)
 
 	if p.config.AuthEnc {
 		// Check that the professed PubKey matches the sconn's.
@@ -171,13 +174,18 @@ func (p *Peer) HandshakeTimeout(ourNodeInfo *NodeInfo, timeout time.Duration) er
 		}
 	}
 
-	// Remove deadline
-	p.conn.SetDeadline(time.Time{})
-
 	peerNodeInfo.RemoteAddr = p.Addr().String()
 
 	p.NodeInfo = peerNodeInfo
-	p.Key = peerNodeInfo.PubKey.KeyString()
+	p.Key = string(peerNodeInfo.ID())
+
+	var chDescs []*ChannelDescriptor
+	for _, chDesc := range p.chDescs {
+		if peerNodeInfo.HasChannel(chDesc.ID) {
+			chDescs = append(chDescs, chDesc)
+		}
+	}
+	p.mconn = createMConnection(p.conn, p, p.reactorsByCh, chDescs, p.onPeerError, p.config.MConfig)
 
 	return nil
 }
@@ -198,6 +206,11 @@ func (p *Peer) PubKey() crypto.PubKeyEd25519 {
 	return p.PubKey()
 }
 
+// ID returns the peer's node ID.
+func (p *Peer) ID() ID {
+	return p.NodeInfo.ID()
+}
+
 // OnStart implements BaseService.
 func (p *Peer) OnStart() error {
 	p.BaseService.OnStart()
@@ -211,7 +224,7 @@ func (p *Peer) OnStop() {
 	p.mconn.Stop()
 }
 
-// Connection returns underlying MConnection.
+// Connection returns underlying MConnection, or nil before the handshake.
 func (p *Peer) Connection() *MConnection {
 	return p.mconn
 }
@@ -221,21 +234,34 @@ func (p *Peer) IsOutbound() bool {
 	return p.outbound
 }
 
+// HasChannel returns true if both we and the peer support the channel.
+func (p *Peer) HasChannel(chID byte) bool {
+	if p.mconn == nil {
+		return false
+	}
+	_, ok := p.mconn.channelsIdx[chID]
+	return ok
+}
+
 // Send msg to the channel identified by chID byte. Returns false if the send
-// queue is full after timeout, specified by MConnection.
+// queue is full after timeout, specified by MConnection, or if the peer doesn't
+// support the channel.
 func (p *Peer) Send(chID byte, msg interface{}) bool {
 	if !p.IsRunning() {
 		// see Switch#Broadcast, where we fetch the list of peers and loop over
 		// them - while we're looping, one peer may be removed and stopped.
 		return false
 	}
+	if !p.HasChannel(chID) {
+		return false
+	}
 	return p.mconn.Send(chID, msg)
 }
 
 // TrySend msg to the channel identified by chID byte. Immediately returns
-// false if the send queue is full.
+// false if the send queue is full, or if the peer doesn't support the channel.
 func (p *Peer) TrySend(chID byte, msg interface{}) bool {
-	if !p.IsRunning() {
+	if !p.IsRunning() || !p.HasChannel(chID) {
 		return false
 	}
 	return p.mconn.TrySend(chID, msg)
@@ -243,7 +269,7 @@ func (p *Peer) TrySend(chID byte, msg interface{}) bool {
 
 // CanSend returns true if the send queue is not full, false otherwise.
 func (p *Peer) CanSend(chID byte) bool {
-	if !p.IsRunning() {
+	if !p.IsRunning() || !p.HasChannel(chID) {
 		return false
 	}
 	return p.mconn.CanSend(chID)
@@ -277,7 +303,7 @@ func (p *Peer) Get(key string) interface{} {
 }
 
 func dial(addr *NetAddress, config *PeerConfig) (net.Conn, error) {
-	conn, err := addr.DialTimeout(config.DialTimeout * time.Second)
+	conn, err := config.Transport.Dial(addr, config.DialTimeout*time.Second)
 	if err != nil {
 		return nil, err
 	}
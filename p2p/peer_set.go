@@ -5,6 +5,7 @@ import (
 )
 
 // IPeerSet has a (immutable) subset of the methods of PeerSet.
+// Peers are keyed by their ID.
 type IPeerSet interface {
 	Has(key string) bool
 	Get(key string) *Peer
@@ -14,7 +15,8 @@ type IPeerSet interface {
 
 //-----------------------------------------------------------------------------
 
-// PeerSet is a special structure for keeping a table of peers.
+// PeerSet is a special structure for keeping a table of peers, keyed by ID,
+// so several peers can share an IP.
 // Iteration over the peers is super fast and thread-safe.
 type PeerSet struct {
 	mtx    sync.Mutex
@@ -34,7 +36,7 @@ func NewPeerSet() *PeerSet {
 	}
 }
 
-// Returns false if peer with key (PubKeyEd25519) is already set
+// Returns ErrSwitchDuplicatePeer if a peer with the same key (ID) is already set
 func (ps *PeerSet) Add(peer *Peer) error {
 	ps.mtx.Lock()
 	defer ps.mtx.Unlock()
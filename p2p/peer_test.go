@@ -55,6 +55,37 @@ func TestPeerWithoutAuthEnc(t *testing.T) {
 	assert.True(p.IsRunning())
 }
 
+func TestPeerWithID(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	config := DefaultPeerConfig()
+
+	// simulate remote peer
+	rp := &remotePeer{PrivKey: crypto.GenPrivKeyEd25519(), Config: config}
+	rp.Start()
+	defer rp.Stop()
+
+	addr := *rp.Addr()
+	addr.ID = PubKeyToID(rp.PubKey().Wrap())
+	p, err := createOutboundPeerAndPerformHandshake(&addr, config)
+	require.Nil(err)
+	p.CloseConn()
+
+	// a different ID
+	addr = *rp.Addr()
+	addr.ID = PubKeyToID(crypto.GenPrivKeyEd25519().PubKey())
+	_, err = createOutboundPeerAndPerformHandshake(&addr, config)
+	assert.NotNil(err)
+
+	// can't authenticate the ID
+	config = DefaultPeerConfig()
+	config.AuthEnc = false
+	addr = *rp.Addr()
+	addr.ID = PubKeyToID(rp.PubKey().Wrap())
+	_, err = createOutboundPeerAndPerformHandshake(&addr, config)
+	assert.NotNil(err)
+}
+
 func TestPeerSend(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 
@@ -144,7 +175,9 @@ func (p *remotePeer) accept(l net.Listener) {
 			Version: "123.123.123",
 		}, 1*time.Second)
 		if err != nil {
-			golog.Fatalf("Failed to perform handshake: %+v", err)
+			// the dialer hangs up if we're not the peer it expected
+			conn.Close()
+			continue
 		}
 		select {
 		case <-p.quit:
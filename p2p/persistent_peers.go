@@ -0,0 +1,254 @@
+package p2p
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+
+The switch keeps a connection to each of its persistent peers: it dials them
+when it starts, and redials them whenever they disconnect, until they are removed.
+
+The redials back off exponentially, from persistent_peers_min_dial_period
+up to persistent_peers_max_dial_period, with each wait drawn at random
+from the upper half of the current period so peers don't redial in lockstep.
+
+*/
+
+// PersistentPeerStatus is the history of the switch's attempts to connect to a persistent peer.
+type PersistentPeerStatus struct {
+	Addr          *NetAddress `json:"addr"`
+	Connected     bool        `json:"connected"`
+	Attempts      int         `json:"attempts"` // failed dials since the last connection
+	LastAttempt   time.Time   `json:"last_attempt"`
+	LastError     string      `json:"last_error"`
+	LastConnected time.Time   `json:"last_connected"`
+}
+
+type persistentPeer struct {
+	mtx     sync.Mutex
+	addr    *NetAddress
+	dialing bool
+	redial  bool // dial again, the peer disconnected while it was being dialed
+	removed chan struct{}
+	status  PersistentPeerStatus
+}
+
+func newPersistentPeer(addr *NetAddress) *persistentPeer {
+	return &persistentPeer{
+		addr:    addr,
+		removed: make(chan struct{}),
+		status:  PersistentPeerStatus{Addr: addr},
+	}
+}
+
+// startDialing returns false if the peer is already being dialed,
+// and has the dialer dial again before it stops.
+func (pp *persistentPeer) startDialing() bool {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+	if pp.dialing {
+		pp.redial = true
+		return false
+	}
+	pp.dialing = true
+	return true
+}
+
+// stopDialing returns false if the dialer has to dial again.
+func (pp *persistentPeer) stopDialing() bool {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+	if pp.redial {
+		pp.redial = false
+		return false
+	}
+	pp.dialing = false
+	return true
+}
+
+// dialed records a dial attempt, and returns the number of failed attempts since the last connection.
+func (pp *persistentPeer) dialed(err error, now time.Time) int {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+	pp.status.LastAttempt = now
+	if err != nil {
+		pp.status.Attempts++
+		pp.status.LastError = err.Error()
+	} else {
+		pp.status.Attempts = 0
+		pp.status.LastConnected = now
+	}
+	return pp.status.Attempts
+}
+
+func (pp *persistentPeer) Status() PersistentPeerStatus {
+	pp.mtx.Lock()
+	defer pp.mtx.Unlock()
+	return pp.status
+}
+
+//-----------------------------------------------------------------------------
+
+// AddPersistentPeers adds the id@host:port addresses to the switch's persistent peers,
+// and dials them if the switch is running.
+func (sw *Switch) AddPersistentPeers(addrs []string) error {
+	netAddrs, err := NewNetAddressStrings(addrs)
+	if err != nil {
+		return err
+	}
+	for _, netAddr := range netAddrs {
+		if netAddr.ID == "" {
+			return fmt.Errorf("Persistent peer %v has no ID; persistent peers must be id@host:port", netAddr)
+		}
+	}
+	for _, netAddr := range netAddrs {
+		pp := sw.addPersistentPeer(netAddr)
+		if sw.IsRunning() {
+			go sw.dialPersistentPeer(pp)
+		}
+	}
+	return nil
+}
+
+// RemovePersistentPeers stops redialing the peers with the IDs. It doesn't disconnect them.
+func (sw *Switch) RemovePersistentPeers(ids []ID) {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	for _, id := range ids {
+		if pp, ok := sw.persistentPeers[id]; ok {
+			close(pp.removed)
+			delete(sw.persistentPeers, id)
+		}
+	}
+}
+
+// PersistentPeers returns the status of each persistent peer.
+func (sw *Switch) PersistentPeers() []PersistentPeerStatus {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	statuses := make([]PersistentPeerStatus, 0, len(sw.persistentPeers))
+	for id, pp := range sw.persistentPeers {
+		status := pp.Status()
+		status.Connected = sw.peers.Has(string(id))
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// addPersistentPeer returns the persistent peer with the address's ID,
+// adding it if there is none. A new address replaces the old one.
+func (sw *Switch) addPersistentPeer(addr *NetAddress) *persistentPeer {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	pp, ok := sw.persistentPeers[addr.ID]
+	if !ok {
+		pp = newPersistentPeer(addr)
+		sw.persistentPeers[addr.ID] = pp
+		return pp
+	}
+	pp.mtx.Lock()
+	pp.addr = addr
+	pp.status.Addr = addr
+	pp.mtx.Unlock()
+	return pp
+}
+
+func (sw *Switch) persistentPeer(id ID) *persistentPeer {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	return sw.persistentPeers[id]
+}
+
+// dialPersistentPeers dials all the persistent peers.
+func (sw *Switch) dialPersistentPeers() {
+	sw.persistentMtx.Lock()
+	defer sw.persistentMtx.Unlock()
+	for _, pp := range sw.persistentPeers {
+		go sw.dialPersistentPeer(pp)
+	}
+}
+
+// dialPersistentPeer dials the peer until it is connected, backing off after each failure.
+// It returns once the peer is connected or removed, or the switch stops.
+// If the peer is already being dialed, it leaves it to that dialer,
+// which dials again in case the peer disconnected after it connected.
+func (sw *Switch) dialPersistentPeer(pp *persistentPeer) {
+	if !pp.startDialing() {
+		return
+	}
+	for {
+		sw.redialPersistentPeer(pp)
+		if pp.stopDialing() {
+			return
+		}
+	}
+}
+
+// redialPersistentPeer is the dial loop of dialPersistentPeer.
+func (sw *Switch) redialPersistentPeer(pp *persistentPeer) {
+	for {
+		select {
+		case <-pp.removed:
+			return
+		case <-sw.Quit:
+			return
+		default:
+		}
+
+		pp.mtx.Lock()
+		addr := pp.addr
+		pp.mtx.Unlock()
+
+		if sw.peers.Has(string(addr.ID)) {
+			return
+		}
+		_, err := sw.dialPeerWithAddress(addr, true)
+		attempts := pp.dialed(err, time.Now())
+		if err == nil {
+			return
+		}
+		if err == ErrSwitchDuplicatePeer {
+			// it dialed us
+			return
+		}
+
+		wait := sw.persistentPeerBackoff(attempts)
+		sw.Logger.Info("Error dialing persistent peer. Trying again", "addr", addr, "tries", attempts, "wait", wait, "err", err)
+		select {
+		case <-time.After(wait):
+		case <-pp.removed:
+			return
+		case <-sw.Quit:
+			return
+		}
+	}
+}
+
+// minPersistentPeerDialPeriod is the least we wait between dials,
+// whatever the config says, so a min dial period of 0 can't hot-loop.
+const minPersistentPeerDialPeriod = 10 * time.Millisecond
+
+// persistentPeerBackoff returns how long to wait after the given number of failed dials.
+func (sw *Switch) persistentPeerBackoff(attempts int) time.Duration {
+	minPeriod := time.Duration(sw.config.PersistentPeersMinDialPeriod) * time.Millisecond
+	maxPeriod := time.Duration(sw.config.PersistentPeersMaxDialPeriod) * time.Millisecond
+	if minPeriod < minPersistentPeerDialPeriod {
+		minPeriod = minPersistentPeerDialPeriod
+	}
+	if maxPeriod < minPeriod {
+		maxPeriod = minPeriod
+	}
+	period := minPeriod
+	for i := 1; i < attempts && period < maxPeriod; i++ {
+		period *= 2
+	}
+	if period > maxPeriod {
+		period = maxPeriod
+	}
+	// jitter: wait between half and all of the period
+	return period/2 + time.Duration(rand.Int63n(int64(period/2)+1))
+}
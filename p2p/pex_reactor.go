@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
 	"time"
 
 	wire "github.com/tendermint/go-wire"
@@ -23,6 +24,16 @@ const (
 	// maximum messages one peer can send to us during `msgCountByPeerFlushInterval`
 	defaultMaxMsgCountByPeer    = 1000
 	msgCountByPeerFlushInterval = 1 * time.Hour
+
+	// in seed mode, peers are disconnected this long after we answer their
+	// pexRequestMessage, and at most seedPeerMaxLifetime after they connect
+	seedDisconnectWaitPeriod = 3 * time.Second
+	seedPeerMaxLifetime      = 30 * time.Second
+
+	// in seed mode, each address is crawled at most once per crawlPeerInterval,
+	// and addresses that failed maxCrawlFailures dials in a row are dropped
+	crawlPeerInterval = 2 * time.Minute
+	maxCrawlFailures  = 10
 )
 
 // PEXReactor handles PEX (peer exchange) and ensures that an
@@ -41,11 +52,21 @@ const (
 //   quality of peer messages so if peerA keeps telling us about peers we can't
 //   connect to then maybe we should care less about peerA. But I don't think
 //   that kind of complexity is priority right now.
+//
+// ## Seed mode
+//
+// A seed node only hands out addresses. Instead of keeping enough peers
+// connected, it crawls the network every `ensurePeersPeriod`: it dials the
+// addresses in its book it hasn't tried for longest, asks each for its peers,
+// and hangs up. Addresses it can't dial are eventually dropped, so the book
+// stays fresh. Inbound peers get an answer to their pexRequestMessage, and are
+// disconnected shortly after.
 type PEXReactor struct {
 	BaseReactor
 
 	book              *AddrBook
 	ensurePeersPeriod time.Duration
+	seedMode          bool
 
 	// tracks message count by peer, so we can prevent abuse
 	msgCountByPeer    *cmn.CMap
@@ -68,7 +89,11 @@ func NewPEXReactor(b *AddrBook) *PEXReactor {
 func (r *PEXReactor) OnStart() error {
 	r.BaseReactor.OnStart()
 	r.book.Start()
-	go r.ensurePeersRoutine()
+	if r.seedMode {
+		go r.crawlPeersRoutine()
+	} else {
+		go r.ensurePeersRoutine()
+	}
 	go r.flushMsgCountByPeer()
 	return nil
 }
@@ -93,15 +118,18 @@ func (r *PEXReactor) GetChannels() []*ChannelDescriptor {
 // AddPeer implements Reactor by adding peer to the address book (if inbound)
 // or by requesting more addresses (if outbound).
 func (r *PEXReactor) AddPeer(p *Peer) {
+	if r.seedMode {
+		go r.disconnectPeerAfter(p, seedPeerMaxLifetime)
+	}
 	if p.IsOutbound() {
 		// For outbound peers, the address is already in the books.
 		// Either it was added in DialSeeds or when we
 		// received the peer's address in r.Receive
-		if r.book.NeedMoreAddrs() {
+		if r.seedMode || r.book.NeedMoreAddrs() {
 			r.RequestPEX(p)
 		}
 	} else { // For inbound connections, the peer is its own source
-		addr, err := NewNetAddressString(p.ListenAddr)
+		addr, err := NewNetAddressString(IDAddressString(p.ID(), p.ListenAddr))
 		if err != nil {
 			// this should never happen
 			r.Logger.Error("Error in AddPeer: invalid peer address", "addr", p.ListenAddr, "err", err)
@@ -120,11 +148,10 @@ func (r *PEXReactor) RemovePeer(p *Peer, reason interface{}) {
 // Receive implements Reactor by handling incoming PEX messages.
 func (r *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 	srcAddr := src.Connection().RemoteAddress
-	srcAddrStr := srcAddr.String()
 
-	r.IncrementMsgCountForPeer(srcAddrStr)
-	if r.ReachedMaxMsgCountForPeer(srcAddrStr) {
-		r.Logger.Error("Maximum number of messages reached for peer", "peer", srcAddrStr)
+	r.IncrementMsgCountForPeer(src.Key)
+	if r.ReachedMaxMsgCountForPeer(src.Key) {
+		r.Logger.Error("Maximum number of messages reached for peer", "peer", src)
 		// TODO remove src from peers?
 		return
 	}
@@ -140,6 +167,10 @@ func (r *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 	case *pexRequestMessage:
 		// src requested some peers.
 		r.SendAddrs(src, r.book.GetSelection())
+		if r.seedMode {
+			// give the addrs time to get there
+			go r.disconnectPeerAfter(src, seedDisconnectWaitPeriod)
+		}
 	case *pexAddrsMessage:
 		// We received some peer addresses from src.
 		// (We don't want to get spammed with bad peers)
@@ -148,6 +179,10 @@ func (r *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 				r.book.AddAddress(addr, srcAddr)
 			}
 		}
+		if r.seedMode {
+			// that's all we wanted
+			go r.disconnectPeerAfter(src, 0)
+		}
 	default:
 		r.Logger.Error(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
 	}
@@ -158,9 +193,15 @@ func (r *PEXReactor) RequestPEX(p *Peer) {
 	p.Send(PexChannel, struct{ PexMessage }{&pexRequestMessage{}})
 }
 
-// SendAddrs sends addrs to the peer.
+// SendAddrs sends addrs to the peer, except those of private peers.
 func (r *PEXReactor) SendAddrs(p *Peer, addrs []*NetAddress) {
-	p.Send(PexChannel, struct{ PexMessage }{&pexAddrsMessage{Addrs: addrs}})
+	public := make([]*NetAddress, 0, len(addrs))
+	for _, addr := range addrs {
+		if !r.book.IsPrivate(addr) {
+			public = append(public, addr)
+		}
+	}
+	p.Send(PexChannel, struct{ PexMessage }{&pexAddrsMessage{Addrs: public}})
 }
 
 // SetEnsurePeersPeriod sets period to ensure peers connected.
@@ -168,27 +209,33 @@ func (r *PEXReactor) SetEnsurePeersPeriod(d time.Duration) {
 	r.ensurePeersPeriod = d
 }
 
+// SetSeedMode sets whether the reactor crawls the network as a seed node,
+// instead of keeping peers connected. It must be called before the reactor starts.
+func (r *PEXReactor) SetSeedMode(seedMode bool) {
+	r.seedMode = seedMode
+}
+
 // SetMaxMsgCountByPeer sets maximum messages one peer can send to us during 'msgCountByPeerFlushInterval'.
 func (r *PEXReactor) SetMaxMsgCountByPeer(v uint16) {
 	r.maxMsgCountByPeer = v
 }
 
 // ReachedMaxMsgCountForPeer returns true if we received too many
-// messages from peer with address `addr`.
+// messages from the peer with key `peerKey`.
 // NOTE: assumes the value in the CMap is non-nil
-func (r *PEXReactor) ReachedMaxMsgCountForPeer(addr string) bool {
-	return r.msgCountByPeer.Get(addr).(uint16) >= r.maxMsgCountByPeer
+func (r *PEXReactor) ReachedMaxMsgCountForPeer(peerKey string) bool {
+	return r.msgCountByPeer.Get(peerKey).(uint16) >= r.maxMsgCountByPeer
 }
 
 // Increment or initialize the msg count for the peer in the CMap
-func (r *PEXReactor) IncrementMsgCountForPeer(addr string) {
+func (r *PEXReactor) IncrementMsgCountForPeer(peerKey string) {
 	var count uint16
-	countI := r.msgCountByPeer.Get(addr)
+	countI := r.msgCountByPeer.Get(peerKey)
 	if countI != nil {
 		count = countI.(uint16)
 	}
 	count++
-	r.msgCountByPeer.Set(addr, count)
+	r.msgCountByPeer.Set(peerKey, count)
 }
 
 // Ensures that sufficient peers are connected. (continuous)
@@ -222,9 +269,10 @@ func (r *PEXReactor) ensurePeersRoutine() {
 // the node operator. It should not be used to compute what addresses are
 // already connected or not.
 //
-// TODO Basically, we need to work harder on our good-peer/bad-peer marking.
-// What we're currently doing in terms of marking good/bad peers is just a
-// placeholder. It should not be the case that an address becomes old/vetted
+// The book prefers addresses of peers with higher trust scores, and forgets
+// those of banned peers (see trust_metric.go).
+//
+// TODO It should not be the case that an address becomes old/vetted
 // upon a single successful connection.
 func (r *PEXReactor) ensurePeers() {
 	numOutPeers, _, numDialing := r.Switch.NumPeers()
@@ -234,7 +282,7 @@ func (r *PEXReactor) ensurePeers() {
 		return
 	}
 
-	toDial := make(map[string]*NetAddress)
+	toDial := make(map[ID]*NetAddress)
 
 	// Try to pick numToDial addresses to dial.
 	for i := 0; i < numToDial; i++ {
@@ -252,9 +300,9 @@ func (r *PEXReactor) ensurePeers() {
 			if try == nil {
 				break
 			}
-			_, alreadySelected := toDial[try.IP.String()]
+			_, alreadySelected := toDial[try.ID]
 			alreadyDialing := r.Switch.IsDialing(try)
-			alreadyConnected := r.Switch.Peers().Has(try.IP.String())
+			alreadyConnected := r.Switch.Peers().Has(string(try.ID))
 			if alreadySelected || alreadyDialing || alreadyConnected {
 				// r.Logger.Info("Cannot dial address", "addr", try,
 				// 	"alreadySelected", alreadySelected,
@@ -270,7 +318,7 @@ func (r *PEXReactor) ensurePeers() {
 		if picked == nil {
 			continue
 		}
-		toDial[picked.IP.String()] = picked
+		toDial[picked.ID] = picked
 	}
 
 	// Dial picked addresses
@@ -294,6 +342,76 @@ func (r *PEXReactor) ensurePeers() {
 	}
 }
 
+// Crawls the network as a seed node. (continuous)
+func (r *PEXReactor) crawlPeersRoutine() {
+	// fire once immediately.
+	r.crawlPeers()
+
+	// fire periodically
+	ticker := time.NewTicker(r.ensurePeersPeriod)
+
+	for {
+		select {
+		case <-ticker.C:
+			r.crawlPeers()
+		case <-r.Quit:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// crawlPeers dials the addresses in the book that were tried longest ago,
+// and not within crawlPeerInterval. AddPeer asks them for their peers. (once)
+func (r *PEXReactor) crawlPeers() {
+	now := time.Now()
+	kas := r.book.ListOfKnownAddresses()
+	sort.Slice(kas, func(i, j int) bool {
+		return kas[i].LastAttempt.Before(kas[j].LastAttempt)
+	})
+
+	numToDial := minNumOutboundPeers
+	for _, ka := range kas {
+		if numToDial == 0 {
+			break
+		}
+		neverTried := ka.Attempts == 0 && ka.LastSuccess.IsZero()
+		if !neverTried && now.Sub(ka.LastAttempt) < crawlPeerInterval {
+			continue
+		}
+		if r.Switch.IsDialing(ka.Addr) || r.Switch.Peers().Has(string(ka.Addr.ID)) {
+			continue
+		}
+		numToDial--
+
+		go func(ka *knownAddress) {
+			_, err := r.Switch.DialPeerWithAddress(ka.Addr, false)
+			if err != nil {
+				if ka.Attempts+1 >= maxCrawlFailures {
+					r.Logger.Info("Dropping address that can't be dialed", "addr", ka.Addr, "err", err)
+					r.book.RemoveAddress(ka.Addr)
+				} else {
+					r.book.MarkAttempt(ka.Addr)
+				}
+				return
+			}
+			r.book.MarkGood(ka.Addr)
+		}(ka)
+	}
+}
+
+// disconnectPeerAfter disconnects the peer after the delay, if it's still connected.
+func (r *PEXReactor) disconnectPeerAfter(p *Peer, delay time.Duration) {
+	select {
+	case <-time.After(delay):
+	case <-r.Quit:
+		return
+	}
+	if r.Switch.Peers().Get(p.Key) == p {
+		r.Switch.StopPeerGracefully(p)
+	}
+}
+
 func (r *PEXReactor) flushMsgCountByPeer() {
 	ticker := time.NewTicker(msgCountByPeerFlushInterval)
 
@@ -4,11 +4,13 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
 	wire "github.com/tendermint/go-wire"
 	cmn "github.com/tendermint/tmlibs/common"
 	"github.com/tendermint/tmlibs/log"
@@ -87,7 +89,7 @@ func TestPEXReactorRunning(t *testing.T) {
 
 	// fill the address book and add listeners
 	for _, s := range switches {
-		addr, _ := NewNetAddressString(s.NodeInfo().ListenAddr)
+		addr, _ := NewNetAddressString(IDAddressString(s.NodeInfo().ID(), s.NodeInfo().ListenAddr))
 		book.AddAddress(addr, addr)
 		s.AddListener(NewDefaultListener("tcp", s.NodeInfo().ListenAddr, true, log.TestingLogger()))
 	}
@@ -129,7 +131,7 @@ func TestPEXReactorReceive(t *testing.T) {
 	peer := createRandomPeer(false)
 
 	size := book.Size()
-	netAddr, _ := NewNetAddressString(peer.ListenAddr)
+	netAddr, _ := NewNetAddressString(IDAddressString(peer.ID(), peer.ListenAddr))
 	addrs := []*NetAddress{netAddr}
 	msg := wire.BinaryBytes(struct{ PexMessage }{&pexAddrsMessage{Addrs: addrs}})
 	r.Receive(PexChannel, peer, msg)
@@ -159,20 +161,161 @@ func TestPEXReactorAbuseFromPeer(t *testing.T) {
 		r.Receive(PexChannel, peer, msg)
 	}
 
-	assert.True(r.ReachedMaxMsgCountForPeer(peer.ListenAddr))
+	assert.True(r.ReachedMaxMsgCountForPeer(peer.Key))
 }
 
 func createRandomPeer(outbound bool) *Peer {
 	addr := cmn.Fmt("%v.%v.%v.%v:46656", rand.Int()%256, rand.Int()%256, rand.Int()%256, rand.Int()%256)
 	netAddr, _ := NewNetAddressString(addr)
+	nodeInfo := &NodeInfo{
+		PubKey:     crypto.GenPrivKeyEd25519().PubKey().Unwrap().(crypto.PubKeyEd25519),
+		ListenAddr: addr,
+	}
 	p := &Peer{
-		Key: cmn.RandStr(12),
-		NodeInfo: &NodeInfo{
-			ListenAddr: addr,
-		},
+		Key:      string(nodeInfo.ID()),
+		NodeInfo: nodeInfo,
 		outbound: outbound,
 		mconn:    &MConnection{RemoteAddress: netAddr},
 	}
 	p.SetLogger(log.TestingLogger().With("peer", addr))
 	return p
 }
+
+func TestPEXReactorKeepsPrivatePeersSecret(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "pex_reactor")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	withPEX := func(book *AddrBook) func(int, *Switch) *Switch {
+		return func(i int, sw *Switch) *Switch {
+			sw.SetLogger(log.TestingLogger().With("switch", i))
+			book.SetLogger(log.TestingLogger().With("switch", i))
+			r := NewPEXReactor(book)
+			r.SetLogger(log.TestingLogger())
+			r.SetEnsurePeersPeriod(250 * time.Millisecond)
+			sw.AddReactor("pex", r)
+			return sw
+		}
+	}
+
+	// a validator without PEX behind its sentry, and another node that knows the sentry
+	validator := makeSwitch(config, 0, "127.0.0.1", "123.123.123", func(i int, sw *Switch) *Switch {
+		sw.SetLogger(log.TestingLogger().With("switch", i))
+		return sw
+	})
+	sentryBook := NewAddrBook(filepath.Join(dir, "sentry_addrbook.json"), false)
+	sentry := makeSwitch(config, 1, "127.0.0.1", "123.123.123", withPEX(sentryBook))
+	otherBook := NewAddrBook(filepath.Join(dir, "other_addrbook.json"), false)
+	other := makeSwitch(config, 2, "127.0.0.1", "123.123.123", withPEX(otherBook))
+
+	validatorID := validator.NodeInfo().ID()
+	sentryBook.AddPrivateIDs([]string{string(validatorID)})
+
+	for _, s := range []*Switch{validator, sentry, other} {
+		s.AddListener(NewDefaultListener("tcp", s.NodeInfo().ListenAddr, true, log.TestingLogger()))
+		defer s.Stop()
+	}
+	sentryAddr, err := NewNetAddressString(IDAddressString(sentry.NodeInfo().ID(), sentry.NodeInfo().ListenAddr))
+	require.Nil(err)
+
+	// the validator connects to its sentry
+	_, err = sentry.Start()
+	require.Nil(err)
+	_, err = validator.Start()
+	require.Nil(err)
+	require.Nil(validator.AddPersistentPeers([]string{sentryAddr.String()}))
+	time.Sleep(500 * time.Millisecond)
+	require.True(sentry.Peers().Has(string(validatorID)))
+
+	// then the other node connects to the sentry, and asks it for addresses
+	otherBook.AddAddress(sentryAddr, sentryAddr)
+	_, err = other.Start()
+	require.Nil(err)
+	time.Sleep(1 * time.Second)
+	require.True(other.Peers().Has(string(sentry.NodeInfo().ID())))
+
+	assert.False(bookHasID(sentryBook, validatorID), "the sentry should not add the validator to its book")
+	assert.False(bookHasID(otherBook, validatorID), "the sentry should not gossip the validator's address")
+	assert.False(other.Peers().Has(string(validatorID)))
+}
+
+func bookHasID(book *AddrBook, id ID) bool {
+	book.mtx.Lock()
+	defer book.mtx.Unlock()
+	for _, ka := range book.addrLookup {
+		if ka.Addr.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPEXReactorSeedMode(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "pex_reactor")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	// only the seed dials on its own
+	withPEX := func(book *AddrBook, seedMode bool) func(int, *Switch) *Switch {
+		return func(i int, sw *Switch) *Switch {
+			sw.SetLogger(log.TestingLogger().With("switch", i))
+			book.SetLogger(log.TestingLogger().With("switch", i))
+			r := NewPEXReactor(book)
+			r.SetLogger(log.TestingLogger())
+			if seedMode {
+				r.SetEnsurePeersPeriod(250 * time.Millisecond)
+			} else {
+				r.SetEnsurePeersPeriod(1 * time.Hour)
+			}
+			r.SetSeedMode(seedMode)
+			sw.AddReactor("pex", r)
+			return sw
+		}
+	}
+	addrOf := func(sw *Switch) *NetAddress {
+		addr, err := NewNetAddressString(IDAddressString(sw.NodeInfo().ID(), sw.NodeInfo().ListenAddr))
+		require.Nil(err)
+		return addr
+	}
+
+	// the seed knows a node, which knows some other address
+	seedBook := NewAddrBook(filepath.Join(dir, "seed_addrbook.json"), false)
+	seed := makeSwitch(config, 0, "127.0.0.1", "123.123.123", withPEX(seedBook, true))
+	nodeBook := NewAddrBook(filepath.Join(dir, "node_addrbook.json"), false)
+	node := makeSwitch(config, 1, "127.0.0.1", "123.123.123", withPEX(nodeBook, false))
+	otherAddr := randIPv4Address(t)
+	nodeBook.AddAddress(otherAddr, otherAddr)
+	seedBook.AddAddress(addrOf(node), addrOf(node))
+
+	for _, s := range []*Switch{seed, node} {
+		s.AddListener(NewDefaultListener("tcp", s.NodeInfo().ListenAddr, true, log.TestingLogger()))
+		_, err := s.Start()
+		require.Nil(err)
+		defer s.Stop()
+	}
+
+	// the seed crawls the node for its addresses, and hangs up
+	time.Sleep(1 * time.Second)
+	assert.True(bookHasID(seedBook, otherAddr.ID), "the seed should learn the node's addresses")
+	assert.False(seed.Peers().Has(string(node.NodeInfo().ID())), "the seed should hang up on the node")
+
+	// a new node connects to the seed for addresses, and gets hung up on
+	newBook := NewAddrBook(filepath.Join(dir, "new_addrbook.json"), false)
+	newNode := makeSwitch(config, 2, "127.0.0.1", "123.123.123", withPEX(newBook, false))
+	_, err = newNode.Start()
+	require.Nil(err)
+	defer newNode.Stop()
+	seedPeer, err := newNode.DialPeerWithAddress(addrOf(seed), false)
+	require.Nil(err)
+
+	time.Sleep(1 * time.Second)
+	assert.True(bookHasID(newBook, node.NodeInfo().ID()), "the seed should answer with the addresses it knows")
+	assert.True(seedPeer.IsRunning())
+
+	time.Sleep(seedDisconnectWaitPeriod)
+	assert.False(seedPeer.IsRunning(), "the seed should hang up after answering")
+}
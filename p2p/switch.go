@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	crypto "github.com/tendermint/go-crypto"
@@ -12,11 +13,6 @@ import (
 	cmn "github.com/tendermint/tmlibs/common"
 )
 
-const (
-	reconnectAttempts = 30
-	reconnectInterval = 3 * time.Second
-)
-
 type Reactor interface {
 	cmn.Service // Start, Stop
 
@@ -67,16 +63,23 @@ type Switch struct {
 	chDescs      []*ChannelDescriptor
 	reactorsByCh map[byte]Reactor
 	peers        *PeerSet
+	ipRanges     *ipRangeLimiter
+	addrBook     *AddrBook // for the peers' trust metrics
 	dialing      *cmn.CMap
 	nodeInfo     *NodeInfo             // our node info
 	nodePrivKey  crypto.PrivKeyEd25519 // our node privkey
 
+	persistentMtx   sync.Mutex
+	persistentPeers map[ID]*persistentPeer
+
 	filterConnByAddr   func(net.Addr) error
 	filterConnByPubKey func(crypto.PubKeyEd25519) error
 }
 
 var (
 	ErrSwitchDuplicatePeer = errors.New("Duplicate peer")
+	ErrSwitchIPRangeLimit  = errors.New("Too many peers from the same IP range")
+	ErrSwitchPeerBanned    = errors.New("Peer is banned")
 )
 
 func NewSwitch(config *cfg.P2PConfig) *Switch {
@@ -87,10 +90,17 @@ func NewSwitch(config *cfg.P2PConfig) *Switch {
 		chDescs:      make([]*ChannelDescriptor, 0),
 		reactorsByCh: make(map[byte]Reactor),
 		peers:        NewPeerSet(),
+		ipRanges:     newIPRangeLimiter(config.MaxPeersPerIPRange),
 		dialing:      cmn.NewCMap(),
 		nodeInfo:     nil,
+
+		persistentPeers: make(map[ID]*persistentPeer),
 	}
 	sw.peerConfig.MConfig.flushThrottle = time.Duration(config.FlushThrottleTimeout) * time.Millisecond // TODO: collapse the peerConfig into the config ?
+	sw.peerConfig.MConfig.SendRate = config.SendRate
+	sw.peerConfig.MConfig.RecvRate = config.RecvRate
+	sw.peerConfig.MConfig.MaxSendRate = config.MaxSendRate
+	sw.peerConfig.MConfig.MaxRecvRate = config.MaxRecvRate
 	sw.BaseService = *cmn.NewBaseService(nil, "P2P Switch", sw)
 	return sw
 }
@@ -111,6 +121,7 @@ func (sw *Switch) AddReactor(name string, reactor Reactor) Reactor {
 	}
 	sw.reactors[name] = reactor
 	reactor.SetSwitch(sw)
+	sw.setNodeInfoChannels()
 	return reactor
 }
 
@@ -145,9 +156,24 @@ func (sw *Switch) IsListening() bool {
 }
 
 // SetNodeInfo sets the switch's NodeInfo for checking compatibility and handshaking with other nodes.
+// The NodeInfo advertises the channels of the switch's reactors.
 // NOTE: Not goroutine safe.
 func (sw *Switch) SetNodeInfo(nodeInfo *NodeInfo) {
 	sw.nodeInfo = nodeInfo
+	sw.setNodeInfoChannels()
+}
+
+// setNodeInfoChannels advertises the reactors' channels in the NodeInfo,
+// so peers open only the channels we both support.
+func (sw *Switch) setNodeInfoChannels() {
+	if sw.nodeInfo == nil {
+		return
+	}
+	chIDs := make([]byte, len(sw.chDescs))
+	for i, chDesc := range sw.chDescs {
+		chIDs[i] = chDesc.ID
+	}
+	sw.nodeInfo.SetChannels(chIDs)
 }
 
 // NodeInfo returns the switch's NodeInfo.
@@ -166,6 +192,26 @@ func (sw *Switch) SetNodePrivKey(nodePrivKey crypto.PrivKeyEd25519) {
 	}
 }
 
+// SetAddrBook sets the address book that keeps the peers' trust metrics.
+// Without one, peer events are ignored and no peers are banned.
+// NOTE: Not goroutine safe.
+func (sw *Switch) SetAddrBook(addrBook *AddrBook) {
+	sw.addrBook = addrBook
+}
+
+// SetTransport sets the transport the switch dials and upgrades peers with.
+// Listeners are made from the transport by the caller, and added with AddListener.
+// NOTE: Not goroutine safe.
+func (sw *Switch) SetTransport(transport Transport) {
+	sw.peerConfig.Transport = transport
+}
+
+// Transport returns the switch's transport.
+// NOTE: Not goroutine safe.
+func (sw *Switch) Transport() Transport {
+	return sw.peerConfig.Transport
+}
+
 // OnStart implements BaseService. It starts all the reactors, peers, and listeners.
 func (sw *Switch) OnStart() error {
 	sw.BaseService.OnStart()
@@ -184,6 +230,8 @@ func (sw *Switch) OnStart() error {
 	for _, listener := range sw.listeners {
 		go sw.listenerRoutine(listener)
 	}
+	// Dial persistent peers
+	sw.dialPersistentPeers()
 	return nil
 }
 
@@ -239,6 +287,17 @@ func (sw *Switch) AddPeer(peer *Peer) error {
 
 	}
 
+	// Refuse banned peers
+	if sw.addrBook != nil && !sw.isTrustedPeer(peer.ID()) && sw.addrBook.IsBanned(peer.ID()) {
+		return ErrSwitchPeerBanned
+	}
+
+	// Check the limits on peers from the peer's IP range.
+	// Of two calls racing to add the same peer, only one gets past this
+	if err := sw.ipRanges.Add(peer.Key, NewNetAddress(peer.Addr()).IP); err != nil {
+		return err
+	}
+
 	// Start peer
 	if sw.IsRunning() {
 		sw.startInitPeer(peer)
@@ -246,8 +305,10 @@ func (sw *Switch) AddPeer(peer *Peer) error {
 
 	// Add the peer to .peers.
 	// We start it first so that a peer in the list is safe to Stop.
-	// It should not err since we already checked peers.Has()
+	// It should not err since we already checked peers.Has(),
+	// and the peer's IP range count is ours to undo if it does
 	if err := sw.peers.Add(peer); err != nil {
+		sw.ipRanges.Remove(peer.Key)
 		return err
 	}
 
@@ -296,11 +357,15 @@ func (sw *Switch) DialSeeds(addrBook *AddrBook, seeds []string) error {
 	if err != nil {
 		return err
 	}
+	for _, netAddr := range netAddrs {
+		if netAddr.ID == "" {
+			return fmt.Errorf("Seed %v has no ID; seeds must be id@host:port", netAddr)
+		}
+	}
 
 	if addrBook != nil {
 		// add seeds to `addrBook`
-		ourAddrS := sw.nodeInfo.ListenAddr
-		ourAddr, _ := NewNetAddressString(ourAddrS)
+		ourAddr, _ := NewNetAddressString(IDAddressString(sw.nodeInfo.ID(), sw.nodeInfo.ListenAddr))
 		for _, netAddr := range netAddrs {
 			// do not add ourselves
 			if netAddr.Equals(ourAddr) {
@@ -324,7 +389,7 @@ func (sw *Switch) DialSeeds(addrBook *AddrBook, seeds []string) error {
 }
 
 func (sw *Switch) dialSeed(addr *NetAddress) {
-	peer, err := sw.DialPeerWithAddress(addr, true)
+	peer, err := sw.DialPeerWithAddress(addr, false)
 	if err != nil {
 		sw.Logger.Error("Error dialing seed", "err", err)
 	} else {
@@ -333,10 +398,27 @@ func (sw *Switch) dialSeed(addr *NetAddress) {
 }
 
 // DialPeerWithAddress dials the given peer and runs sw.AddPeer if it connects successfully.
-// If `persistent == true`, the switch will always try to reconnect to this peer if the connection ever fails.
+// If `persistent == true`, the peer is added to the persistent peers, and the switch
+// will always try to reconnect to it if the dial or the connection ever fails.
 func (sw *Switch) DialPeerWithAddress(addr *NetAddress, persistent bool) (*Peer, error) {
-	sw.dialing.Set(addr.IP.String(), addr)
-	defer sw.dialing.Delete(addr.IP.String())
+	if !persistent {
+		return sw.dialPeerWithAddress(addr, false)
+	}
+	if addr.ID == "" {
+		return nil, fmt.Errorf("Persistent peer %v has no ID", addr)
+	}
+	pp := sw.addPersistentPeer(addr)
+	peer, err := sw.dialPeerWithAddress(addr, true)
+	pp.dialed(err, time.Now())
+	if err != nil && sw.IsRunning() {
+		go sw.dialPersistentPeer(pp)
+	}
+	return peer, err
+}
+
+func (sw *Switch) dialPeerWithAddress(addr *NetAddress, persistent bool) (*Peer, error) {
+	sw.dialing.Set(addr.String(), addr)
+	defer sw.dialing.Delete(addr.String())
 
 	sw.Logger.Info("Dialing peer", "address", addr)
 	peer, err := newOutboundPeer(addr, sw.reactorsByCh, sw.chDescs, sw.StopPeerForError, sw.nodePrivKey, sw.peerConfig)
@@ -360,7 +442,7 @@ func (sw *Switch) DialPeerWithAddress(addr *NetAddress, persistent bool) (*Peer,
 
 // IsDialing returns true if the switch is currently dialing the given address.
 func (sw *Switch) IsDialing(addr *NetAddress) bool {
-	return sw.dialing.Has(addr.IP.String())
+	return sw.dialing.Has(addr.String())
 }
 
 // Broadcast runs a go routine for each attempted send, which will block
@@ -399,37 +481,38 @@ func (sw *Switch) Peers() IPeerSet {
 	return sw.peers
 }
 
+// ReportPeerEvent records something the peer did in its trust metric,
+// and disconnects it if that gets it banned. Reactors call it.
+// Events about persistent and private peers are ignored.
+func (sw *Switch) ReportPeerEvent(peer *Peer, event PeerEvent) {
+	if sw.addrBook == nil || sw.isTrustedPeer(peer.ID()) {
+		return
+	}
+	banned := sw.addrBook.RecordPeerEvent(peer.ID(), event)
+	if banned && sw.peers.Get(peer.Key) == peer {
+		sw.StopPeerForError(peer, ErrSwitchPeerBanned)
+	}
+}
+
+// isTrustedPeer returns true if the peer with the ID is persistent or private.
+// Those are trusted by configuration, so they're never banned.
+func (sw *Switch) isTrustedPeer(id ID) bool {
+	if sw.persistentPeer(id) != nil {
+		return true
+	}
+	return sw.addrBook != nil && sw.addrBook.IsPrivateID(id)
+}
+
 // StopPeerForError disconnects from a peer due to external error.
 // If the peer is persistent, it will attempt to reconnect.
 // TODO: make record depending on reason.
 func (sw *Switch) StopPeerForError(peer *Peer, reason interface{}) {
-	addr := NewNetAddress(peer.Addr())
 	sw.Logger.Error("Stopping peer for error", "peer", peer, "err", reason)
 	sw.stopAndRemovePeer(peer, reason)
 
-	if peer.IsPersistent() {
-		go func() {
-			sw.Logger.Info("Reconnecting to peer", "peer", peer)
-			for i := 1; i < reconnectAttempts; i++ {
-				if !sw.IsRunning() {
-					return
-				}
-
-				peer, err := sw.DialPeerWithAddress(addr, true)
-				if err != nil {
-					if i == reconnectAttempts {
-						sw.Logger.Info("Error reconnecting to peer. Giving up", "tries", i, "err", err)
-						return
-					}
-					sw.Logger.Info("Error reconnecting to peer. Trying again", "tries", i, "err", err)
-					time.Sleep(reconnectInterval)
-					continue
-				}
-
-				sw.Logger.Info("Reconnected to peer", "peer", peer)
-				return
-			}
-		}()
+	if pp := sw.persistentPeer(peer.ID()); pp != nil && sw.IsRunning() {
+		sw.Logger.Info("Reconnecting to peer", "peer", peer)
+		go sw.dialPersistentPeer(pp)
 	}
 }
 
@@ -442,6 +525,7 @@ func (sw *Switch) StopPeerGracefully(peer *Peer) {
 
 func (sw *Switch) stopAndRemovePeer(peer *Peer, reason interface{}) {
 	sw.peers.Remove(peer)
+	sw.ipRanges.Remove(peer.Key)
 	peer.Stop()
 	for _, reactor := range sw.reactors {
 		reactor.RemovePeer(peer, reason)
@@ -558,7 +642,7 @@ func makeSwitch(cfg *cfg.P2PConfig, i int, network, version string, initSwitch f
 	privKey := crypto.GenPrivKeyEd25519()
 	// new switch, add reactors
 	// TODO: let the config be passed in?
-	s := initSwitch(i, NewSwitch(cfg))
+	s := NewSwitch(cfg)
 	s.SetNodeInfo(&NodeInfo{
 		PubKey:     privKey.PubKey().Unwrap().(crypto.PubKeyEd25519),
 		Moniker:    cmn.Fmt("switch%d", i),
@@ -568,7 +652,8 @@ func makeSwitch(cfg *cfg.P2PConfig, i int, network, version string, initSwitch f
 		ListenAddr: cmn.Fmt("%v:%v", network, rand.Intn(64512)+1023),
 	})
 	s.SetNodePrivKey(privKey)
-	return s
+	// initSwitch can add to the NodeInfo
+	return initSwitch(i, s)
 }
 
 func (sw *Switch) addPeerWithConnection(conn net.Conn) error {
@@ -163,6 +163,42 @@ func TestSwitches(t *testing.T) {
 
 }
 
+func TestSwitchNegotiatesChannels(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	// the second switch doesn't run the "bar" reactor
+	s1, s2 := makeSwitchPair(t, func(i int, sw *Switch) *Switch {
+		if i == 0 {
+			return initSwitchFunc(i, sw)
+		}
+		sw.AddReactor("foo", NewTestReactor([]*ChannelDescriptor{
+			&ChannelDescriptor{ID: byte(0x00), Priority: 10},
+			&ChannelDescriptor{ID: byte(0x01), Priority: 10},
+		}, true))
+		return sw
+	})
+	defer s1.Stop()
+	defer s2.Stop()
+
+	chIDs, ok := s2.NodeInfo().Channels()
+	require.True(ok)
+	assert.Equal([]byte{0x00, 0x01}, chIDs)
+
+	require.Equal(1, s1.Peers().Size())
+	peer := s1.Peers().List()[0]
+	assert.True(peer.HasChannel(0x00))
+	assert.False(peer.HasChannel(0x02))
+
+	// sending on a channel the peer doesn't support fails, but keeps the peer
+	assert.False(peer.Send(0x02, "channel bar"))
+	assert.False(peer.TrySend(0x02, "channel bar"))
+	assert.True(peer.Send(0x00, "channel zero"))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(1, s1.Peers().Size())
+	assert.Equal(1, len(s2.Reactor("foo").(*TestReactor).getMsgs(0x00)))
+}
+
 func TestConnAddrFilter(t *testing.T) {
 	s1 := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
 	s2 := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
@@ -258,10 +294,60 @@ func TestSwitchStopsNonPersistentPeerOnError(t *testing.T) {
 	assert.False(peer.IsRunning())
 }
 
-func TestSwitchReconnectsToPersistentPeer(t *testing.T) {
+func TestSwitchAddPeerRace(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	sw := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	sw.Start()
+	defer sw.Stop()
+
+	rp := &remotePeer{PrivKey: crypto.GenPrivKeyEd25519(), Config: DefaultPeerConfig()}
+	rp.Start()
+	defer rp.Stop()
+
+	for i := 0; i < 10; i++ {
+		// two connections to the same peer, added at once
+		var peers [2]*Peer
+		var errs [2]error
+		for j := range peers {
+			peer, err := newOutboundPeer(rp.Addr(), sw.reactorsByCh, sw.chDescs, sw.StopPeerForError, sw.nodePrivKey, DefaultPeerConfig())
+			require.Nil(err)
+			peers[j] = peer
+		}
+		var wg sync.WaitGroup
+		for j := range peers {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				errs[j] = sw.AddPeer(peers[j])
+			}(j)
+		}
+		wg.Wait()
+
+		// one wins, and the loser leaves the winner's IP range count alone
+		winner, loser := 0, 1
+		if errs[0] != nil {
+			winner, loser = 1, 0
+		}
+		require.Nil(errs[winner])
+		assert.Equal(ErrSwitchDuplicatePeer, errs[loser])
+		peers[loser].CloseConn()
+		assert.Equal(1, sw.Peers().Size())
+		assert.Equal(1, len(sw.ipRanges.peers))
+
+		sw.StopPeerGracefully(peers[winner])
+		assert.Zero(sw.Peers().Size())
+		assert.Zero(len(sw.ipRanges.counts))
+	}
+}
+
+func TestSwitchBansUntrustedPeer(t *testing.T) {
 	assert, require := assert.New(t), require.New(t)
 
 	sw := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	book := NewAddrBook(createTempFileName("addrbook_test"), false)
+	book.SetLogger(log.TestingLogger())
+	sw.SetAddrBook(book)
 	sw.Start()
 	defer sw.Stop()
 
@@ -270,11 +356,70 @@ func TestSwitchReconnectsToPersistentPeer(t *testing.T) {
 	rp.Start()
 	defer rp.Stop()
 
-	peer, err := newOutboundPeer(rp.Addr(), sw.reactorsByCh, sw.chDescs, sw.StopPeerForError, sw.nodePrivKey, DefaultPeerConfig())
-	peer.makePersistent()
+	peer, err := sw.DialPeerWithAddress(rp.Addr(), false)
 	require.Nil(err)
-	err = sw.AddPeer(peer)
+
+	sw.ReportPeerEvent(peer, PeerEventTimeout)
+	sw.ReportPeerEvent(peer, PeerEventTimeout)
+	sw.ReportPeerEvent(peer, PeerEventInvalidBlock)
+	assert.Equal(1, sw.Peers().Size())
+
+	sw.ReportPeerEvent(peer, PeerEventInvalidBlock)
+	assert.Zero(sw.Peers().Size())
+	assert.False(peer.IsRunning())
+
+	_, err = sw.DialPeerWithAddress(rp.Addr(), false)
+	assert.Equal(ErrSwitchPeerBanned, err)
+}
+
+func TestSwitchDoesntBanPersistentPeer(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	sw := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	book := NewAddrBook(createTempFileName("addrbook_test"), false)
+	book.SetLogger(log.TestingLogger())
+	sw.SetAddrBook(book)
+	sw.Start()
+	defer sw.Stop()
+
+	// simulate remote peer
+	rp := &remotePeer{PrivKey: crypto.GenPrivKeyEd25519(), Config: DefaultPeerConfig()}
+	rp.Start()
+	defer rp.Stop()
+
+	addr := *rp.Addr()
+	addr.ID = PubKeyToID(rp.PubKey().Wrap())
+	require.Nil(sw.AddPersistentPeers([]string{addr.String()}))
+	for i := 0; i < 100 && sw.Peers().Size() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(1, sw.Peers().Size())
+	peer := sw.Peers().List()[0]
+
+	for i := 0; i < 10; i++ {
+		sw.ReportPeerEvent(peer, PeerEventInvalidBlock)
+	}
+	assert.Equal(1, sw.Peers().Size())
+	assert.False(book.IsBanned(addr.ID))
+}
+
+func TestSwitchReconnectsToPersistentPeer(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	sw := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	sw.Start()
+	defer sw.Stop()
+
+	// simulate remote peer
+	rp := &remotePeer{PrivKey: crypto.GenPrivKeyEd25519(), Config: DefaultPeerConfig()}
+	rp.Start()
+	defer rp.Stop()
+
+	addr := *rp.Addr()
+	addr.ID = PubKeyToID(rp.PubKey().Wrap())
+	peer, err := sw.DialPeerWithAddress(&addr, true)
 	require.Nil(err)
+	assert.True(peer.IsPersistent())
 
 	// simulate failure by closing connection
 	peer.CloseConn()
@@ -284,6 +429,90 @@ func TestSwitchReconnectsToPersistentPeer(t *testing.T) {
 
 	assert.NotZero(sw.Peers().Size())
 	assert.False(peer.IsRunning())
+
+	statuses := sw.PersistentPeers()
+	require.Equal(1, len(statuses))
+	assert.True(statuses[0].Connected)
+	assert.Equal(addr.ID, statuses[0].Addr.ID)
+
+	// once removed, it's not redialed
+	sw.RemovePersistentPeers([]ID{addr.ID})
+	assert.Empty(sw.PersistentPeers())
+	sw.StopPeerForError(sw.Peers().List()[0], "test")
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(sw.Peers().Size())
+}
+
+func TestSwitchRedialsPersistentPeerWithBackoff(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	config := cfg.TestP2PConfig()
+	sw := makeSwitch(config, 1, "testing", "123.123.123", initSwitchFunc)
+	sw.Start()
+	defer sw.Stop()
+
+	// nothing is listening at the address yet
+	rpPrivKey := crypto.GenPrivKeyEd25519()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(err)
+	addr := NewNetAddress(l.Addr())
+	addr.ID = PubKeyToID(rpPrivKey.PubKey())
+	l.Close()
+
+	err = sw.AddPersistentPeers([]string{addr.String()})
+	require.Nil(err)
+	time.Sleep(500 * time.Millisecond)
+
+	statuses := sw.PersistentPeers()
+	require.Equal(1, len(statuses))
+	assert.False(statuses[0].Connected)
+	// 10ms, then 20, 40, 80, and 100ms at most, with jitter
+	assert.True(statuses[0].Attempts >= 5, "attempts: %d", statuses[0].Attempts)
+	assert.True(statuses[0].Attempts <= 20, "attempts: %d", statuses[0].Attempts)
+	assert.NotEmpty(statuses[0].LastError)
+}
+
+func TestSwitchRedialsPersistentPeerLostWhileDialing(t *testing.T) {
+	assert := assert.New(t)
+
+	addr, err := NewNetAddressString("127.0.0.1:46656")
+	require.Nil(t, err)
+	pp := newPersistentPeer(addr)
+
+	// a dialer is running, and the peer it just connected to drops
+	assert.True(pp.startDialing())
+	assert.False(pp.startDialing(), "the running dialer dials it")
+
+	// so the running dialer goes round again before it stops
+	assert.False(pp.stopDialing())
+	assert.True(pp.stopDialing())
+	assert.True(pp.startDialing())
+}
+
+func TestSwitchPersistentPeerBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	config := cfg.TestP2PConfig()
+	sw := NewSwitch(config)
+	for attempts, period := range []time.Duration{10, 10, 20, 40, 80, 100, 100} {
+		period *= time.Millisecond
+		wait := sw.persistentPeerBackoff(attempts)
+		assert.True(wait >= period/2 && wait <= period, "attempts: %d, wait: %v", attempts, wait)
+	}
+
+	// a min of 0, or a max below the min, still waits
+	config.PersistentPeersMinDialPeriod = 0
+	config.PersistentPeersMaxDialPeriod = 0
+	for attempts := 0; attempts < 5; attempts++ {
+		wait := sw.persistentPeerBackoff(attempts)
+		assert.True(wait >= minPersistentPeerDialPeriod/2 && wait <= minPersistentPeerDialPeriod, "wait: %v", wait)
+	}
+	config.PersistentPeersMinDialPeriod = 50
+	config.PersistentPeersMaxDialPeriod = 20
+	for attempts := 0; attempts < 5; attempts++ {
+		wait := sw.persistentPeerBackoff(attempts)
+		assert.True(wait >= 25*time.Millisecond && wait <= 50*time.Millisecond, "wait: %v", wait)
+	}
 }
 
 func BenchmarkSwitches(b *testing.B) {
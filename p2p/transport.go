@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	crypto "github.com/tendermint/go-crypto"
+	wire "github.com/tendermint/go-wire"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+)
+
+/*
+
+A Transport is how the switch reaches its peers: it dials them, accepts their
+connections, and upgrades the connections with the SecretConnection and
+NodeInfo handshakes. The switch and peers only ever see net.Conns and Listeners,
+so other transports can be added without touching them.
+
+TCPTransport is the default. MemTransport connects switches in memory,
+with simulated latency and loss, for tests and simulations without sockets.
+
+*/
+
+// Transport dials, accepts and upgrades connections to peers.
+type Transport interface {
+	// Dial connects to the address, or times out.
+	Dial(addr *NetAddress, timeout time.Duration) (net.Conn, error)
+
+	// Listen accepts connections to the address, passing them on the listener's Connections().
+	Listen(addr *NetAddress) (Listener, error)
+
+	// Upgrade authenticates and encrypts the connection, within the timeout.
+	Upgrade(conn net.Conn, ourPrivKey crypto.PrivKeyEd25519, timeout time.Duration) (*SecretConnection, error)
+
+	// Handshake exchanges NodeInfos with the peer, within the timeout.
+	Handshake(conn net.Conn, ourNodeInfo *NodeInfo, timeout time.Duration) (*NodeInfo, error)
+}
+
+//-----------------------------------------------------------------------------
+
+// TCPTransport is the default Transport, over TCP.
+type TCPTransport struct {
+	UPNP   bool       // if true, Listen tries UPnP to find its external address
+	Logger log.Logger // for the listeners; nil means no logging
+}
+
+var _ Transport = TCPTransport{}
+
+// Dial implements Transport.
+func (TCPTransport) Dial(addr *NetAddress, timeout time.Duration) (net.Conn, error) {
+	return addr.DialTimeout(timeout)
+}
+
+// Listen implements Transport. It returns an error if it can't bind the address.
+func (t TCPTransport) Listen(addr *NetAddress) (Listener, error) {
+	logger := t.Logger
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	l, err := newDefaultListener("tcp", addr.DialString(), !t.UPNP, logger)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Upgrade implements Transport.
+func (TCPTransport) Upgrade(conn net.Conn, ourPrivKey crypto.PrivKeyEd25519, timeout time.Duration) (*SecretConnection, error) {
+	return upgradeConn(conn, ourPrivKey, timeout)
+}
+
+// Handshake implements Transport.
+func (TCPTransport) Handshake(conn net.Conn, ourNodeInfo *NodeInfo, timeout time.Duration) (*NodeInfo, error) {
+	return handshakeConn(conn, ourNodeInfo, timeout)
+}
+
+//-----------------------------------------------------------------------------
+
+// upgradeConn makes a SecretConnection of the conn, within the timeout.
+func upgradeConn(conn net.Conn, ourPrivKey crypto.PrivKeyEd25519, timeout time.Duration) (*SecretConnection, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+	sconn, err := MakeSecretConnection(conn, ourPrivKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating peer")
+	}
+	return sconn, nil
+}
+
+// handshakeConn writes our NodeInfo to the conn and reads the peer's, within the timeout.
+func handshakeConn(conn net.Conn, ourNodeInfo *NodeInfo, timeout time.Duration) (*NodeInfo, error) {
+	// Set deadline for handshake so we don't block forever on conn.ReadFull
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var peerNodeInfo = new(NodeInfo)
+	var err1 error
+	var err2 error
+	cmn.Parallel(
+		func() {
+			var n int
+			wire.WriteBinary(ourNodeInfo, conn, &n, &err1)
+		},
+		func() {
+			var n int
+			wire.ReadBinary(peerNodeInfo, conn, maxNodeInfoSize, &n, &err2)
+		})
+	if err1 != nil {
+		return nil, errors.Wrap(err1, "Error during handshake/write")
+	}
+	if err2 != nil {
+		return nil, errors.Wrap(err2, "Error during handshake/read")
+	}
+
+	// Remove deadline
+	conn.SetDeadline(time.Time{})
+
+	return peerNodeInfo, nil
+}
@@ -0,0 +1,142 @@
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+/*
+
+The trust metric is how much we trust a peer, from what the reactors report
+about it: useful data counts for it, and timeouts, bad votes and invalid blocks
+count against it. Every event decays with a half life of trustMetricHalfLife,
+so a peer's past matters less and less.
+
+The score is (good + prior) / (good + bad + 2*prior), between 0 and 1, and 0.5
+for a peer we know nothing about. The prior of trustMetricPrior events each way
+means one bad event never bans a new peer: it takes a couple of invalid blocks,
+or many timeouts, that useful data doesn't make up for. When a peer's score falls
+below the trust threshold, it's banned for the ban period: the switch disconnects
+it, refuses its connections, and the address book forgets its addresses and won't
+learn them again. Persistent and private peers are trusted by configuration,
+and never banned.
+
+The metrics, and so the bans, are stored with the address book, keyed by node ID,
+so they survive restarts.
+
+*/
+
+const (
+	// time for the weight of an event to halve.
+	trustMetricHalfLife = time.Hour
+
+	// metrics that decay below this total weight, and aren't banned, are forgotten.
+	trustMetricMinWeight = 0.01
+
+	// weight of the events we assume, for and against, before we know anything about a peer.
+	trustMetricPrior = 5
+
+	defaultTrustThreshold = 0.2
+	defaultBanPeriod      = 24 * time.Hour
+)
+
+// PeerEvent is something a peer did that changes how much we trust it.
+type PeerEvent int
+
+const (
+	PeerEventUsefulData   PeerEvent = iota // sent us data we needed
+	PeerEventTimeout                       // didn't send us what we asked for in time
+	PeerEventBadVote                       // sent a vote with a bad signature
+	PeerEventInvalidBlock                  // sent a block, or block part, that failed validation
+)
+
+// weights of each event, for and against the peer
+var peerEventWeights = map[PeerEvent]struct{ good, bad float64 }{
+	PeerEventUsefulData:   {good: 1},
+	PeerEventTimeout:      {bad: 1},
+	PeerEventBadVote:      {bad: 4},
+	PeerEventInvalidBlock: {bad: 10},
+}
+
+func (e PeerEvent) String() string {
+	switch e {
+	case PeerEventUsefulData:
+		return "UsefulData"
+	case PeerEventTimeout:
+		return "Timeout"
+	case PeerEventBadVote:
+		return "BadVote"
+	case PeerEventInvalidBlock:
+		return "InvalidBlock"
+	default:
+		return fmt.Sprintf("PeerEvent(%d)", int(e))
+	}
+}
+
+// PeerTrust is the trust metric of a peer, for the RPC.
+type PeerTrust struct {
+	ID          ID        `json:"id"`
+	Score       float64   `json:"score"`
+	Good        float64   `json:"good"` // decayed weight of the events for the peer
+	Bad         float64   `json:"bad"`  // decayed weight of the events against the peer
+	BannedUntil time.Time `json:"banned_until"`
+}
+
+//-----------------------------------------------------------------------------
+
+// trustMetric is the decayed weight of the events for and against a peer,
+// as of LastUpdate.
+type trustMetric struct {
+	Good        float64
+	Bad         float64
+	LastUpdate  time.Time
+	BannedUntil time.Time
+}
+
+// decayed returns the weights as of now.
+func (tm *trustMetric) decayed(now time.Time) (good, bad float64) {
+	elapsed := now.Sub(tm.LastUpdate)
+	if tm.LastUpdate.IsZero() || elapsed <= 0 {
+		return tm.Good, tm.Bad
+	}
+	factor := math.Pow(0.5, float64(elapsed)/float64(trustMetricHalfLife))
+	return tm.Good * factor, tm.Bad * factor
+}
+
+func (tm *trustMetric) record(event PeerEvent, now time.Time) {
+	tm.Good, tm.Bad = tm.decayed(now)
+	tm.LastUpdate = now
+	weights := peerEventWeights[event]
+	tm.Good += weights.good
+	tm.Bad += weights.bad
+}
+
+func (tm *trustMetric) score(now time.Time) float64 {
+	good, bad := tm.decayed(now)
+	return (good + trustMetricPrior) / (good + bad + 2*trustMetricPrior)
+}
+
+func (tm *trustMetric) isBanned(now time.Time) bool {
+	return now.Before(tm.BannedUntil)
+}
+
+// isForgettable returns true if the metric no longer says anything about the peer.
+func (tm *trustMetric) isForgettable(now time.Time) bool {
+	good, bad := tm.decayed(now)
+	return !tm.isBanned(now) && good+bad < trustMetricMinWeight
+}
+
+func (tm *trustMetric) peerTrust(id ID, now time.Time) PeerTrust {
+	good, bad := tm.decayed(now)
+	pt := PeerTrust{
+		ID:    id,
+		Score: tm.score(now),
+		Good:  good,
+		Bad:   bad,
+	}
+	if tm.isBanned(now) {
+		pt.BannedUntil = tm.BannedUntil
+	}
+	return pt
+}
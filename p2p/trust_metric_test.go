@@ -0,0 +1,64 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustMetric(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	tm := &trustMetric{}
+	assert.Equal(0.5, tm.score(now), "we know nothing about the peer")
+
+	tm.record(PeerEventUsefulData, now)
+	assert.True(tm.score(now) > 0.5)
+
+	tm.record(PeerEventBadVote, now)
+	assert.InDelta(6.0/15, tm.score(now), 1e-9)
+
+	// the events halve in weight every half life
+	later := now.Add(trustMetricHalfLife)
+	good, bad := tm.decayed(later)
+	assert.InDelta(0.5, good, 1e-9)
+	assert.InDelta(2, bad, 1e-9)
+	assert.InDelta(5.5/12.5, tm.score(later), 1e-9)
+
+	// and are eventually forgotten
+	muchLater := now.Add(20 * trustMetricHalfLife)
+	assert.InDelta(0.5, tm.score(muchLater), 1e-3)
+	assert.True(tm.isForgettable(muchLater))
+
+	// unless the peer is banned
+	tm.BannedUntil = muchLater.Add(time.Minute)
+	assert.True(tm.isBanned(muchLater))
+	assert.False(tm.isForgettable(muchLater))
+	assert.False(tm.isBanned(tm.BannedUntil))
+}
+
+func TestTrustMetricToleratesHonestMistakes(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+
+	// a slow peer during fast sync
+	tm := &trustMetric{}
+	tm.record(PeerEventTimeout, now)
+	tm.record(PeerEventTimeout, now)
+	assert.True(tm.score(now) > defaultTrustThreshold)
+
+	// a single bad vote, or invalid block, from a new peer
+	tm = &trustMetric{}
+	tm.record(PeerEventBadVote, now)
+	assert.True(tm.score(now) > defaultTrustThreshold)
+	tm = &trustMetric{}
+	tm.record(PeerEventInvalidBlock, now)
+	assert.True(tm.score(now) > defaultTrustThreshold)
+
+	// but not repeated misbehavior
+	tm.record(PeerEventInvalidBlock, now)
+	assert.True(tm.score(now) < defaultTrustThreshold)
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strconv"
@@ -11,6 +12,11 @@ import (
 
 const maxNodeInfoSize = 10240 // 10Kb
 
+// nodeInfoChannelsPrefix starts the NodeInfo.Other entry with the IDs of the
+// channels the node supports, in hex. It's in Other, rather than a field of its own,
+// so nodes from before channel negotiation can still read our NodeInfo.
+const nodeInfoChannelsPrefix = "channels="
+
 type NodeInfo struct {
 	PubKey     crypto.PubKeyEd25519 `json:"pub_key"`
 	Moniker    string               `json:"moniker"`
@@ -54,6 +60,54 @@ func (info *NodeInfo) CompatibleWith(other *NodeInfo) error {
 	return nil
 }
 
+// ID returns the node's ID.
+func (info *NodeInfo) ID() ID {
+	return PubKeyToID(info.PubKey.Wrap())
+}
+
+// SetChannels advertises the IDs of the channels the node supports,
+// replacing any advertised before.
+func (info *NodeInfo) SetChannels(chIDs []byte) {
+	other := make([]string, 0, len(info.Other)+1)
+	for _, s := range info.Other {
+		if !strings.HasPrefix(s, nodeInfoChannelsPrefix) {
+			other = append(other, s)
+		}
+	}
+	info.Other = append(other, nodeInfoChannelsPrefix+hex.EncodeToString(chIDs))
+}
+
+// Channels returns the IDs of the channels the node supports. It returns false
+// if the node doesn't advertise them, like nodes from before channel negotiation,
+// in which case it's assumed to support them all.
+func (info *NodeInfo) Channels() ([]byte, bool) {
+	for _, s := range info.Other {
+		if !strings.HasPrefix(s, nodeInfoChannelsPrefix) {
+			continue
+		}
+		chIDs, err := hex.DecodeString(strings.TrimPrefix(s, nodeInfoChannelsPrefix))
+		if err != nil {
+			return nil, false
+		}
+		return chIDs, true
+	}
+	return nil, false
+}
+
+// HasChannel returns true if the node supports the channel.
+func (info *NodeInfo) HasChannel(chID byte) bool {
+	chIDs, ok := info.Channels()
+	if !ok {
+		return true
+	}
+	for _, id := range chIDs {
+		if id == chID {
+			return true
+		}
+	}
+	return false
+}
+
 func (info *NodeInfo) ListenHost() string {
 	host, _, _ := net.SplitHostPort(info.ListenAddr)
 	return host
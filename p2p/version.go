@@ -1,3 +1,3 @@
 package p2p
 
-const Version = "0.5.0"
+const Version = "0.6.0"
@@ -24,6 +24,7 @@ type AppConnMempool interface {
 	SetResponseCallback(abcicli.Callback)
 	Error() error
 
+	SetOptionAsync(key string, value string) *abcicli.ReqRes
 	CheckTxAsync(tx []byte) *abcicli.ReqRes
 
 	FlushAsync() *abcicli.ReqRes
@@ -110,6 +111,10 @@ func (app *appConnMempool) FlushSync() error {
 	return app.appConn.FlushSync()
 }
 
+func (app *appConnMempool) SetOptionAsync(key string, value string) *abcicli.ReqRes {
+	return app.appConn.SetOptionAsync(key, value)
+}
+
 func (app *appConnMempool) CheckTxAsync(tx []byte) *abcicli.ReqRes {
 	return app.appConn.CheckTxAsync(tx)
 }
@@ -151,6 +151,15 @@ func (c *HTTP) Block(height int) (*ctypes.ResultBlock, error) {
 	return result, nil
 }
 
+func (c *HTTP) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	result := new(ctypes.ResultBlock)
+	_, err := c.rpc.Call("block_by_hash", map[string]interface{}{"hash": hash}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlockByHash")
+	}
+	return result, nil
+}
+
 func (c *HTTP) Commit(height int) (*ctypes.ResultCommit, error) {
 	result := new(ctypes.ResultCommit)
 	_, err := c.rpc.Call("commit", map[string]interface{}{"height": height}, result)
@@ -160,6 +169,15 @@ func (c *HTTP) Commit(height int) (*ctypes.ResultCommit, error) {
 	return result, nil
 }
 
+func (c *HTTP) CommitByHash(hash []byte) (*ctypes.ResultCommit, error) {
+	result := new(ctypes.ResultCommit)
+	_, err := c.rpc.Call("commit_by_hash", map[string]interface{}{"hash": hash}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "CommitByHash")
+	}
+	return result, nil
+}
+
 func (c *HTTP) Tx(hash []byte, prove bool) (*ctypes.ResultTx, error) {
 	result := new(ctypes.ResultTx)
 	query := map[string]interface{}{
@@ -43,7 +43,9 @@ type ABCIClient interface {
 // signatures and prove anything about the chain
 type SignClient interface {
 	Block(height int) (*ctypes.ResultBlock, error)
+	BlockByHash(hash []byte) (*ctypes.ResultBlock, error)
 	Commit(height int) (*ctypes.ResultCommit, error)
+	CommitByHash(hash []byte) (*ctypes.ResultCommit, error)
 	Validators() (*ctypes.ResultValidators, error)
 	Tx(hash []byte, prove bool) (*ctypes.ResultTx, error)
 }
@@ -85,6 +85,14 @@ func (c Local) DialSeeds(seeds []string) (*ctypes.ResultDialSeeds, error) {
 	return core.UnsafeDialSeeds(seeds)
 }
 
+func (c Local) AddPersistentPeers(peers []string) (*ctypes.ResultPersistentPeers, error) {
+	return core.UnsafeAddPersistentPeers(peers)
+}
+
+func (c Local) RemovePersistentPeers(ids []string) (*ctypes.ResultPersistentPeers, error) {
+	return core.UnsafeRemovePersistentPeers(ids)
+}
+
 func (c Local) BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchainInfo, error) {
 	return core.BlockchainInfo(minHeight, maxHeight)
 }
@@ -97,10 +105,18 @@ func (c Local) Block(height int) (*ctypes.ResultBlock, error) {
 	return core.Block(height)
 }
 
+func (c Local) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	return core.BlockByHash(hash)
+}
+
 func (c Local) Commit(height int) (*ctypes.ResultCommit, error) {
 	return core.Commit(height)
 }
 
+func (c Local) CommitByHash(hash []byte) (*ctypes.ResultCommit, error) {
+	return core.CommitByHash(hash)
+}
+
 func (c Local) Validators() (*ctypes.ResultValidators, error) {
 	return core.Validators()
 }
@@ -108,6 +108,14 @@ func (c Client) DialSeeds(seeds []string) (*ctypes.ResultDialSeeds, error) {
 	return core.UnsafeDialSeeds(seeds)
 }
 
+func (c Client) AddPersistentPeers(peers []string) (*ctypes.ResultPersistentPeers, error) {
+	return core.UnsafeAddPersistentPeers(peers)
+}
+
+func (c Client) RemovePersistentPeers(ids []string) (*ctypes.ResultPersistentPeers, error) {
+	return core.UnsafeRemovePersistentPeers(ids)
+}
+
 func (c Client) BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchainInfo, error) {
 	return core.BlockchainInfo(minHeight, maxHeight)
 }
@@ -120,10 +128,18 @@ func (c Client) Block(height int) (*ctypes.ResultBlock, error) {
 	return core.Block(height)
 }
 
+func (c Client) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	return core.BlockByHash(hash)
+}
+
 func (c Client) Commit(height int) (*ctypes.ResultCommit, error) {
 	return core.Commit(height)
 }
 
+func (c Client) CommitByHash(hash []byte) (*ctypes.ResultCommit, error) {
+	return core.CommitByHash(hash)
+}
+
 func (c Client) Validators() (*ctypes.ResultValidators, error) {
 	return core.Validators()
 }
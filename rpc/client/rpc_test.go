@@ -169,6 +169,15 @@ func TestAppCalls(t *testing.T) {
 		require.Nil(err, "%d: %+v", i, err)
 		assert.Equal(block.Block.LastCommit, commit2.Commit)
 
+		// and we can look up the block and commit by hash
+		blockHash := block.BlockMeta.BlockID.Hash
+		hblock, err := c.BlockByHash(blockHash)
+		require.Nil(err, "%d: %+v", i, err)
+		assert.Equal(block.BlockMeta, hblock.BlockMeta)
+		hcommit, err := c.CommitByHash(blockHash)
+		require.Nil(err, "%d: %+v", i, err)
+		assert.Equal(commit.Commit, hcommit.Commit)
+
 		// and we got a proof that works!
 		pres, err := c.ABCIQuery("/key", k, true)
 		if assert.Nil(err) && assert.True(pres.Code.IsOK()) {
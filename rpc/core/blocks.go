@@ -49,6 +49,15 @@ func Block(height int) (*ctypes.ResultBlock, error) {
 	return &ctypes.ResultBlock{blockMeta, block}, nil
 }
 
+// BlockByHash returns the block with the given hash.
+func BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	height, err := blockHeightByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return Block(height)
+}
+
 //-----------------------------------------------------------------------------
 
 func Commit(height int) (*ctypes.ResultCommit, error) {
@@ -73,3 +82,25 @@ func Commit(height int) (*ctypes.ResultCommit, error) {
 	commit := blockStore.LoadBlockCommit(height)
 	return &ctypes.ResultCommit{header, commit, true}, nil
 }
+
+// CommitByHash returns the commit for the block with the given hash.
+func CommitByHash(hash []byte) (*ctypes.ResultCommit, error) {
+	height, err := blockHeightByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return Commit(height)
+}
+
+//-----------------------------------------------------------------------------
+
+func blockHeightByHash(hash []byte) (int, error) {
+	if len(hash) == 0 {
+		return 0, fmt.Errorf("Hash must not be empty")
+	}
+	height := blockStore.LoadBlockHeightByHash(hash)
+	if height == 0 {
+		return 0, fmt.Errorf("Block %X not found", hash)
+	}
+	return height, nil
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"fmt"
 	"os"
 	"runtime/pprof"
 
@@ -12,6 +13,13 @@ func UnsafeFlushMempool() (*ctypes.ResultUnsafeFlushMempool, error) {
 	return &ctypes.ResultUnsafeFlushMempool{}, nil
 }
 
+func UnsafeRemoveTx(hash []byte) (*ctypes.ResultUnsafeRemoveTx, error) {
+	if !mempool.RemoveTx(hash) {
+		return nil, fmt.Errorf("Tx (%X) is not in the mempool", hash)
+	}
+	return &ctypes.ResultUnsafeRemoveTx{}, nil
+}
+
 var profFile *os.File
 
 func UnsafeStartCPUProfiler(filename string) (*ctypes.ResultUnsafeProfile, error) {
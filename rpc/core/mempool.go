@@ -6,6 +6,7 @@ import (
 
 	abci "github.com/tendermint/abci/types"
 	data "github.com/tendermint/go-wire/data"
+	mempl "github.com/tendermint/tendermint/mempool"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	"github.com/tendermint/tendermint/types"
 )
@@ -17,7 +18,7 @@ import (
 func BroadcastTxAsync(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
 	err := mempool.CheckTx(tx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Error broadcasting transaction: %v", err)
+		return nil, broadcastError(err)
 	}
 	return &ctypes.ResultBroadcastTx{Hash: tx.Hash()}, nil
 }
@@ -29,7 +30,7 @@ func BroadcastTxSync(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
 		resCh <- res
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error broadcasting transaction: %v", err)
+		return nil, broadcastError(err)
 	}
 	res := <-resCh
 	r := res.GetCheckTx()
@@ -60,7 +61,7 @@ func BroadcastTxCommit(tx types.Tx) (*ctypes.ResultBroadcastTxCommit, error) {
 	})
 	if err != nil {
 		logger.Error("err", "err", err)
-		return nil, fmt.Errorf("Error broadcasting transaction: %v", err)
+		return nil, broadcastError(err)
 	}
 	checkTxRes := <-checkTxResCh
 	checkTxR := checkTxRes.GetCheckTx()
@@ -104,11 +105,61 @@ func BroadcastTxCommit(tx types.Tx) (*ctypes.ResultBroadcastTxCommit, error) {
 	panic("Should never happen!")
 }
 
-func UnconfirmedTxs() (*ctypes.ResultUnconfirmedTxs, error) {
-	txs := mempool.Reap(-1)
-	return &ctypes.ResultUnconfirmedTxs{len(txs), txs}, nil
+// broadcastError passes on the mempool's limit errors as they are,
+// so clients can tell them apart.
+func broadcastError(err error) error {
+	switch err.(type) {
+	case mempl.ErrTxTooLarge, mempl.ErrMempoolIsFull:
+		return err
+	}
+	return fmt.Errorf("Error broadcasting transaction: %v", err)
+}
+
+const maxPerPage = 100
+
+// UnconfirmedTxs returns the txs in the mempool, highest priority first.
+// A limit of 0, the default, returns all of them; otherwise they come in pages
+// of limit txs, up to maxPerPage. Pages start at 1.
+func UnconfirmedTxs(limit, page int) (*ctypes.ResultUnconfirmedTxs, error) {
+	start := 0
+	if limit <= 0 {
+		limit = -1
+	} else {
+		if limit > maxPerPage {
+			limit = maxPerPage
+		}
+		if page <= 0 {
+			page = 1
+		}
+		start = (page - 1) * limit
+	}
+
+	total := mempool.Size()
+	if start > 0 && start >= total {
+		return nil, fmt.Errorf("Page %d is past the last page of %d txs", page, total)
+	}
+	txs := mempool.TxsPage(start, limit)
+	return &ctypes.ResultUnconfirmedTxs{N: len(txs), Total: total, TotalBytes: mempool.TxsBytes(), Txs: txs}, nil
 }
 
 func NumUnconfirmedTxs() (*ctypes.ResultUnconfirmedTxs, error) {
-	return &ctypes.ResultUnconfirmedTxs{N: mempool.Size()}, nil
+	return &ctypes.ResultUnconfirmedTxs{N: mempool.Size(), Total: mempool.Size(), TotalBytes: mempool.TxsBytes()}, nil
+}
+
+// MempoolTx returns the pending tx with the given hash,
+// with the result of its CheckTx and when it arrived.
+func MempoolTx(hash []byte) (*ctypes.ResultMempoolTx, error) {
+	info := mempool.TxInfo(hash)
+	if info == nil {
+		return nil, fmt.Errorf("Tx (%X) is not in the mempool", hash)
+	}
+	return &ctypes.ResultMempoolTx{
+		Hash:      hash,
+		Tx:        info.Tx,
+		Height:    info.Height,
+		Time:      info.Time,
+		CheckTx:   info.CheckTx,
+		Priority:  info.Priority,
+		GasWanted: info.GasWanted,
+	}, nil
 }
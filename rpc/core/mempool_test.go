@@ -0,0 +1,92 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	cfg "github.com/tendermint/tendermint/config"
+	mempl "github.com/tendermint/tendermint/mempool"
+	"github.com/tendermint/tendermint/proxy"
+	"github.com/tendermint/tendermint/types"
+)
+
+// setTestMempool sets an empty mempool, with an app that accepts every tx.
+func setTestMempool(t *testing.T) *mempl.Mempool {
+	config := cfg.ResetTestRoot("rpc_core_test")
+	appConnMem, _ := proxy.NewLocalClientCreator(abci.NewBaseApplication()).NewABCIClient()
+	if _, err := appConnMem.Start(); err != nil {
+		t.Fatalf("Error starting ABCI client: %v", err.Error())
+	}
+	mem := mempl.NewMempool(config.Mempool, appConnMem, 0)
+	SetMempool(mem)
+	return mem
+}
+
+func TestUnconfirmedTxs(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	setTestMempool(t)
+
+	var txs types.Txs
+	for i := 0; i < 5; i++ {
+		tx := types.Tx{byte(i)}
+		require.Nil(mempool.CheckTx(tx, nil))
+		txs = append(txs, tx)
+	}
+
+	// without a limit, every tx
+	res, err := UnconfirmedTxs(0, 0)
+	require.Nil(err)
+	assert.Equal(txs, types.Txs(res.Txs))
+	assert.Equal(5, res.N)
+	assert.Equal(5, res.Total)
+
+	// pages of 2
+	res, err = UnconfirmedTxs(2, 1)
+	require.Nil(err)
+	assert.Equal(txs[:2], types.Txs(res.Txs))
+	assert.Equal(5, res.Total)
+	res, err = UnconfirmedTxs(2, 3)
+	require.Nil(err)
+	assert.Equal(txs[4:], types.Txs(res.Txs))
+	assert.Equal(1, res.N)
+
+	// past the last page
+	_, err = UnconfirmedTxs(2, 4)
+	assert.NotNil(err)
+
+	// the page size is capped
+	for i := 5; i < maxPerPage+1; i++ {
+		require.Nil(mempool.CheckTx(types.Tx{byte(i)}, nil))
+	}
+	res, err = UnconfirmedTxs(maxPerPage+1, 1)
+	require.Nil(err)
+	assert.Equal(maxPerPage, res.N)
+	assert.Equal(maxPerPage+1, res.Total)
+}
+
+func TestMempoolTxAndRemoveTx(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	setTestMempool(t)
+
+	tx := types.Tx("tx")
+	require.Nil(mempool.CheckTx(tx, nil))
+
+	res, err := MempoolTx(tx.Hash())
+	require.Nil(err)
+	assert.Equal(tx, res.Tx)
+	assert.EqualValues(tx.Hash(), res.Hash)
+	assert.Equal(abci.CodeType_OK, res.CheckTx.Code)
+
+	_, err = UnsafeRemoveTx(tx.Hash())
+	require.Nil(err)
+	assert.Equal(0, mempool.Size())
+
+	// it's gone
+	_, err = MempoolTx(tx.Hash())
+	assert.NotNil(err)
+	_, err = UnsafeRemoveTx(tx.Hash())
+	assert.NotNil(err)
+}
@@ -3,7 +3,10 @@ package core
 import (
 	"fmt"
 
+	mempl "github.com/tendermint/tendermint/mempool"
+	"github.com/tendermint/tendermint/p2p"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
 )
 
 //-----------------------------------------------------------------------------
@@ -16,16 +19,28 @@ func NetInfo() (*ctypes.ResultNetInfo, error) {
 	}
 	peers := []ctypes.Peer{}
 	for _, peer := range p2pSwitch.Peers().List() {
-		peers = append(peers, ctypes.Peer{
+		p := ctypes.Peer{
 			NodeInfo:         *peer.NodeInfo,
+			NodeID:           peer.ID(),
 			IsOutbound:       peer.IsOutbound(),
 			ConnectionStatus: peer.Connection().Status(),
-		})
+		}
+		if ps, ok := peer.Get(types.PeerMempoolStateKey).(*mempl.PeerState); ok {
+			stats := ps.Stats()
+			p.MempoolStats = &stats
+		}
+		peers = append(peers, p)
+	}
+	peerTrusts := []p2p.PeerTrust{}
+	if addrBook != nil {
+		peerTrusts = addrBook.PeerTrusts()
 	}
 	return &ctypes.ResultNetInfo{
-		Listening: listening,
-		Listeners: listeners,
-		Peers:     peers,
+		Listening:       listening,
+		Listeners:       listeners,
+		Peers:           peers,
+		PersistentPeers: p2pSwitch.PersistentPeers(),
+		PeerTrusts:      peerTrusts,
 	}, nil
 }
 
@@ -46,6 +61,33 @@ func UnsafeDialSeeds(seeds []string) (*ctypes.ResultDialSeeds, error) {
 	return &ctypes.ResultDialSeeds{"Dialing seeds in progress. See /net_info for details"}, nil
 }
 
+// Add the id@host:port peers to the persistent peers, and dial them
+func UnsafeAddPersistentPeers(peers []string) (*ctypes.ResultPersistentPeers, error) {
+	if len(peers) == 0 {
+		return &ctypes.ResultPersistentPeers{}, fmt.Errorf("No peers provided")
+	}
+	logger.Info("AddPersistentPeers", "peers", peers)
+	if err := p2pSwitch.AddPersistentPeers(peers); err != nil {
+		return &ctypes.ResultPersistentPeers{}, err
+	}
+	return &ctypes.ResultPersistentPeers{p2pSwitch.PersistentPeers()}, nil
+}
+
+// Remove the peers with the IDs from the persistent peers.
+// They stay connected until they disconnect
+func UnsafeRemovePersistentPeers(ids []string) (*ctypes.ResultPersistentPeers, error) {
+	if len(ids) == 0 {
+		return &ctypes.ResultPersistentPeers{}, fmt.Errorf("No IDs provided")
+	}
+	logger.Info("RemovePersistentPeers", "ids", ids)
+	peerIDs := make([]p2p.ID, len(ids))
+	for i, id := range ids {
+		peerIDs[i] = p2p.ID(id)
+	}
+	p2pSwitch.RemovePersistentPeers(peerIDs)
+	return &ctypes.ResultPersistentPeers{p2pSwitch.PersistentPeers()}, nil
+}
+
 //-----------------------------------------------------------------------------
 
 func Genesis() (*ctypes.ResultGenesis, error) {
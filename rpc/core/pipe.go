@@ -25,6 +25,9 @@ type P2P interface {
 	NodeInfo() *p2p.NodeInfo
 	IsListening() bool
 	DialSeeds(*p2p.AddrBook, []string) error
+	AddPersistentPeers([]string) error
+	RemovePersistentPeers([]p2p.ID)
+	PersistentPeers() []p2p.PersistentPeerStatus
 }
 
 //----------------------------------------------
@@ -16,12 +16,15 @@ var Routes = map[string]*rpc.RPCFunc{
 	"blockchain":           rpc.NewRPCFunc(BlockchainInfo, "minHeight,maxHeight"),
 	"genesis":              rpc.NewRPCFunc(Genesis, ""),
 	"block":                rpc.NewRPCFunc(Block, "height"),
+	"block_by_hash":        rpc.NewRPCFunc(BlockByHash, "hash"),
 	"commit":               rpc.NewRPCFunc(Commit, "height"),
+	"commit_by_hash":       rpc.NewRPCFunc(CommitByHash, "hash"),
 	"tx":                   rpc.NewRPCFunc(Tx, "hash,prove"),
 	"validators":           rpc.NewRPCFunc(Validators, ""),
 	"dump_consensus_state": rpc.NewRPCFunc(DumpConsensusState, ""),
-	"unconfirmed_txs":      rpc.NewRPCFunc(UnconfirmedTxs, ""),
+	"unconfirmed_txs":      rpc.NewRPCFunc(UnconfirmedTxs, "limit,page"),
 	"num_unconfirmed_txs":  rpc.NewRPCFunc(NumUnconfirmedTxs, ""),
+	"mempool_tx":           rpc.NewRPCFunc(MempoolTx, "hash"),
 
 	// broadcast API
 	"broadcast_tx_commit": rpc.NewRPCFunc(BroadcastTxCommit, "tx"),
@@ -36,7 +39,10 @@ var Routes = map[string]*rpc.RPCFunc{
 func AddUnsafeRoutes() {
 	// control API
 	Routes["dial_seeds"] = rpc.NewRPCFunc(UnsafeDialSeeds, "seeds")
+	Routes["add_persistent_peers"] = rpc.NewRPCFunc(UnsafeAddPersistentPeers, "peers")
+	Routes["remove_persistent_peers"] = rpc.NewRPCFunc(UnsafeRemovePersistentPeers, "ids")
 	Routes["unsafe_flush_mempool"] = rpc.NewRPCFunc(UnsafeFlushMempool, "")
+	Routes["remove_tx"] = rpc.NewRPCFunc(UnsafeRemoveTx, "hash")
 
 	// profiler API
 	Routes["unsafe_start_cpu_profiler"] = rpc.NewRPCFunc(UnsafeStartCPUProfiler, "filename")
@@ -21,8 +21,10 @@ func Status() (*ctypes.ResultStatus, error) {
 		latestBlockTime = latestBlockMeta.Header.Time.UnixNano()
 	}
 
+	nodeInfo := p2pSwitch.NodeInfo()
 	return &ctypes.ResultStatus{
-		NodeInfo:          p2pSwitch.NodeInfo(),
+		NodeInfo:          nodeInfo,
+		NodeID:            nodeInfo.ID(),
 		PubKey:            pubKey,
 		LatestBlockHash:   latestBlockHash,
 		LatestAppHash:     latestAppHash,
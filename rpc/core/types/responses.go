@@ -2,6 +2,7 @@ package core_types
 
 import (
 	"strings"
+	"time"
 
 	abci "github.com/tendermint/abci/types"
 	"github.com/tendermint/go-crypto"
@@ -33,6 +34,7 @@ type ResultCommit struct {
 
 type ResultStatus struct {
 	NodeInfo          *p2p.NodeInfo `json:"node_info"`
+	NodeID            p2p.ID        `json:"node_id"`
 	PubKey            crypto.PubKey `json:"pub_key"`
 	LatestBlockHash   data.Bytes    `json:"latest_block_hash"`
 	LatestAppHash     data.Bytes    `json:"latest_app_hash"`
@@ -54,19 +56,27 @@ func (s *ResultStatus) TxIndexEnabled() bool {
 }
 
 type ResultNetInfo struct {
-	Listening bool     `json:"listening"`
-	Listeners []string `json:"listeners"`
-	Peers     []Peer   `json:"peers"`
+	Listening       bool                       `json:"listening"`
+	Listeners       []string                   `json:"listeners"`
+	Peers           []Peer                     `json:"peers"`
+	PersistentPeers []p2p.PersistentPeerStatus `json:"persistent_peers"`
+	PeerTrusts      []p2p.PeerTrust            `json:"peer_trusts"` // includes banned peers
 }
 
 type ResultDialSeeds struct {
 	Log string `json:"log"`
 }
 
+type ResultPersistentPeers struct {
+	PersistentPeers []p2p.PersistentPeerStatus `json:"persistent_peers"`
+}
+
 type Peer struct {
 	p2p.NodeInfo     `json:"node_info"`
-	IsOutbound       bool                 `json:"is_outbound"`
-	ConnectionStatus p2p.ConnectionStatus `json:"connection_status"`
+	NodeID           p2p.ID                  `json:"node_id"`
+	IsOutbound       bool                    `json:"is_outbound"`
+	ConnectionStatus p2p.ConnectionStatus    `json:"connection_status"`
+	MempoolStats     *types.MempoolPeerStats `json:"mempool_stats,omitempty"`
 }
 
 type ResultValidators struct {
@@ -103,8 +113,20 @@ type ResultTx struct {
 }
 
 type ResultUnconfirmedTxs struct {
-	N   int        `json:"n_txs"`
-	Txs []types.Tx `json:"txs"`
+	N          int        `json:"n_txs"`
+	Total      int        `json:"total"`
+	TotalBytes int64      `json:"total_bytes"`
+	Txs        []types.Tx `json:"txs"`
+}
+
+type ResultMempoolTx struct {
+	Hash      data.Bytes  `json:"hash"`
+	Tx        types.Tx    `json:"tx"`
+	Height    int         `json:"height"`
+	Time      time.Time   `json:"time"`
+	CheckTx   abci.Result `json:"check_tx"`
+	Priority  int64       `json:"priority"`
+	GasWanted int64       `json:"gas_wanted"`
 }
 
 type ResultABCIInfo struct {
@@ -117,6 +139,8 @@ type ResultABCIQuery struct {
 
 type ResultUnsafeFlushMempool struct{}
 
+type ResultUnsafeRemoveTx struct{}
+
 type ResultUnsafeProfile struct{}
 
 type ResultSubscribe struct{}
@@ -33,6 +33,10 @@ type (
 		Got      *State
 		Expected *State
 	}
+
+	ErrNoCommitMarker struct {
+		Height int
+	}
 )
 
 func (e ErrUnknownBlock) Error() string {
@@ -53,3 +57,7 @@ func (e ErrLastStateMismatch) Error() string {
 func (e ErrStateMismatch) Error() string {
 	return cmn.Fmt("State after replay does not match saved state. Got ----\n%v\nExpected ----\n%v\n", e.Got, e.Expected)
 }
+
+func (e ErrNoCommitMarker) Error() string {
+	return cmn.Fmt("App committed block %d but there is no commit marker for it", e.Height)
+}
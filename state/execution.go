@@ -4,9 +4,9 @@ import (
 	"errors"
 	"fmt"
 
-	fail "github.com/ebuchman/fail-test"
 	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/tendermint/fail"
 	"github.com/tendermint/tendermint/proxy"
 	"github.com/tendermint/tendermint/state/txindex"
 	"github.com/tendermint/tendermint/types"
@@ -14,6 +14,15 @@ import (
 	"github.com/tendermint/tmlibs/log"
 )
 
+// Crash points for testing recovery, see package fail.
+var (
+	failApplyBlockAfterExec            = fail.Point("state/ApplyBlock/afterExec")
+	failApplyBlockAfterSaveABCIResps   = fail.Point("state/ApplyBlock/afterSaveABCIResponses")
+	failApplyBlockAfterCommit          = fail.Point("state/ApplyBlock/afterCommit")
+	failCommitStateBeforeCommit        = fail.Point("state/CommitStateUpdateMempool/beforeCommit")
+	failCommitStateBeforeMempoolUpdate = fail.Point("state/CommitStateUpdateMempool/beforeMempoolUpdate")
+)
+
 //--------------------------------------------------
 // Execute the block
 
@@ -205,6 +214,18 @@ func (s *State) validateBlock(block *types.Block) error {
 // ApplyBlock validates & executes the block, updates state w/ ABCI responses,
 // then commits and updates the mempool atomically, then saves state.
 // Transaction results are optionally indexed.
+//
+// The writes happen in this order (see also ConsensusState.finalizeCommit):
+//   1. the ABCIResponses and the commit marker for the block, in one batch
+//   2. app.Commit
+//   3. the State, whose LastBlockHeight then catches up with the commit marker
+// So on restart, if the block store is one block ahead of the state:
+//   - no commit marker: the app did not commit the block,
+//     so it is replayed against the real app
+//   - commit marker, app behind: the app did not commit the block,
+//     so it is replayed against the real app
+//   - commit marker, app caught up: the app committed the block,
+//     so it is replayed against a mock app using the saved ABCIResponses
 
 // Validate, execute, and commit block against app, save block and state
 func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConnConsensus,
@@ -215,7 +236,7 @@ func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConn
 		return fmt.Errorf("Exec failed for application: %v", err)
 	}
 
-	fail.Fail() // XXX
+	fail.Fail(failApplyBlockAfterExec)
 
 	// index txs. This could run in the background
 	s.indexTxs(abciResponses)
@@ -223,7 +244,7 @@ func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConn
 	// save the results before we commit
 	s.SaveABCIResponses(abciResponses)
 
-	fail.Fail() // XXX
+	fail.Fail(failApplyBlockAfterSaveABCIResps)
 
 	// now update the block and validators
 	s.SetBlockAndValidators(block.Header, partsHeader, abciResponses)
@@ -234,7 +255,7 @@ func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConn
 		return fmt.Errorf("Commit failed for application: %v", err)
 	}
 
-	fail.Fail() // XXX
+	fail.Fail(failApplyBlockAfterCommit)
 
 	// save the state
 	s.Save()
@@ -249,6 +270,8 @@ func (s *State) CommitStateUpdateMempool(proxyAppConn proxy.AppConnConsensus, bl
 	mempool.Lock()
 	defer mempool.Unlock()
 
+	fail.Fail(failCommitStateBeforeCommit)
+
 	// Commit block, get hash back
 	res := proxyAppConn.CommitSync()
 	if res.IsErr() {
@@ -264,8 +287,11 @@ func (s *State) CommitStateUpdateMempool(proxyAppConn proxy.AppConnConsensus, bl
 	// Set the state's new AppHash
 	s.AppHash = res.Data
 
-	// Update mempool.
-	mempool.Update(block.Height, block.Txs)
+	fail.Fail(failCommitStateBeforeMempoolUpdate)
+
+	// Update mempool, passing on the Commit Log,
+	// which may say what txs it needs to recheck.
+	mempool.Update(block.Height, block.Txs, res.Log)
 
 	return nil
 }
@@ -289,20 +315,26 @@ func (s *State) indexTxs(abciResponses *ABCIResponses) {
 // Exec and commit a block on the proxyApp without validating or mutating the state
 // Returns the application root hash (result of abci.Commit)
 func ExecCommitBlock(appConnConsensus proxy.AppConnConsensus, block *types.Block, logger log.Logger) ([]byte, error) {
+	_, appHash, err := execCommitBlock(appConnConsensus, block, logger)
+	return appHash, err
+}
+
+// execCommitBlock is ExecCommitBlock, but also returns the ABCIResponses for the block.
+func execCommitBlock(appConnConsensus proxy.AppConnConsensus, block *types.Block, logger log.Logger) (*ABCIResponses, []byte, error) {
 	var eventCache types.Fireable // nil
-	_, err := execBlockOnProxyApp(eventCache, appConnConsensus, block, logger)
+	abciResponses, err := execBlockOnProxyApp(eventCache, appConnConsensus, block, logger)
 	if err != nil {
 		logger.Error("Error executing block on proxy app", "height", block.Height, "err", err)
-		return nil, err
+		return nil, nil, err
 	}
 	// Commit block, get hash back
 	res := appConnConsensus.CommitSync()
 	if res.IsErr() {
 		logger.Error("Error in proxyAppConn.CommitSync", "err", res)
-		return nil, res
+		return nil, nil, res
 	}
 	if res.Log != "" {
 		logger.Info("Commit.Log: " + res.Log)
 	}
-	return res.Data, nil
+	return abciResponses, res.Data, nil
 }
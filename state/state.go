@@ -12,6 +12,7 @@ import (
 	"github.com/tendermint/tmlibs/log"
 
 	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/fail"
 	"github.com/tendermint/tendermint/state/txindex"
 	"github.com/tendermint/tendermint/state/txindex/null"
 	"github.com/tendermint/tendermint/types"
@@ -20,6 +21,9 @@ import (
 var (
 	stateKey         = []byte("stateKey")
 	abciResponsesKey = []byte("abciResponsesKey")
+	commitMarkerKey  = []byte("commitMarkerKey")
+
+	failSaveBeforeWrite = fail.Point("state/Save/beforeWrite")
 )
 
 //-----------------------------------------------------------------------------
@@ -93,14 +97,22 @@ func (s *State) Copy() *State {
 func (s *State) Save() {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
+	fail.Fail(failSaveBeforeWrite)
 	s.db.SetSync(stateKey, s.Bytes())
 }
 
 // Sets the ABCIResponses in the state and writes them to disk
-// in case we crash after app.Commit and before s.Save()
+// in case we crash after app.Commit and before s.Save().
+// The commit marker for abciResponses.Height is written in the same batch,
+// recording that the block is about to be committed by the app.
 func (s *State) SaveABCIResponses(abciResponses *ABCIResponses) {
-	// save the validators to the db
-	s.db.SetSync(abciResponsesKey, abciResponses.Bytes())
+	batch := s.db.NewBatch()
+	batch.Set(abciResponsesKey, abciResponses.Bytes())
+	batch.Set(commitMarkerKey, wire.BinaryBytes(abciResponses.Height))
+	batch.Write()
+
+	// Flush
+	s.db.SetSync(nil, nil)
 }
 
 func (s *State) LoadABCIResponses() *ABCIResponses {
@@ -119,6 +131,32 @@ func (s *State) LoadABCIResponses() *ABCIResponses {
 	return abciResponses
 }
 
+// LoadCommitMarker returns the height of the last block
+// whose ABCIResponses were saved before committing it to the app,
+// or 0 if there is none.
+func (s *State) LoadCommitMarker() int {
+	buf := s.db.Get(commitMarkerKey)
+	if len(buf) == 0 {
+		return 0
+	}
+	var height int
+	r, n, err := bytes.NewReader(buf), new(int), new(error)
+	wire.ReadBinaryPtr(&height, r, 0, n, err)
+	if *err != nil {
+		// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
+		cmn.Exit(cmn.Fmt("LoadCommitMarker: Data has been corrupted or its spec has changed: %v\n", *err))
+	}
+	return height
+}
+
+// CommitPending returns true if the commit marker is ahead of the state,
+// ie. we may have crashed while the app was committing the next block.
+// The marker itself is never cleared: the check stops matching
+// once the state's LastBlockHeight advances to it.
+func (s *State) CommitPending() bool {
+	return s.LoadCommitMarker() == s.LastBlockHeight+1
+}
+
 func (s *State) Equals(s2 *State) bool {
 	return bytes.Equal(s.Bytes(), s2.Bytes())
 }
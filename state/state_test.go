@@ -76,3 +76,32 @@ func TestABCIResponsesSaveLoad(t *testing.T) {
 	abciResponses2 := state.LoadABCIResponses()
 	assert.Equal(abciResponses, abciResponses2, fmt.Sprintf("ABCIResponses don't match: Got %v, Expected %v", abciResponses2, abciResponses))
 }
+
+func TestCommitMarker(t *testing.T) {
+	assert := assert.New(t)
+
+	config := cfg.ResetTestRoot("state_")
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := GetState(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger())
+
+	// nothing to commit at genesis
+	assert.Equal(0, state.LoadCommitMarker())
+	assert.False(state.CommitPending())
+
+	// saving the responses for the next block marks it as pending
+	block := makeBlock(1, state)
+	state.SaveABCIResponses(NewABCIResponses(block))
+	assert.Equal(1, state.LoadCommitMarker())
+	assert.True(state.CommitPending())
+
+	// a reloaded state sees the same marker
+	assert.True(LoadState(stateDB).CommitPending())
+
+	// once the state for the block is saved, the marker no longer matches
+	state.LastBlockHeight = 1
+	state.Save()
+	assert.Equal(1, state.LoadCommitMarker())
+	assert.False(state.CommitPending())
+	assert.False(LoadState(stateDB).CommitPending())
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/tendermint/proxy"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+	dbm "github.com/tendermint/tmlibs/db"
+	"github.com/tendermint/tmlibs/log"
+)
+
+// ChainDivergence is the first place where re-executing the chain
+// gave a different result than the one recorded by the node.
+type ChainDivergence struct {
+	Height   int
+	TxIndex  int // index of the tx in the block, or -1 if the app hash diverged
+	Expected string
+	Got      string
+}
+
+func (d *ChainDivergence) String() string {
+	if d.TxIndex < 0 {
+		return cmn.Fmt("App hash after block %d diverges. Expected %s, got %s", d.Height, d.Expected, d.Got)
+	}
+	return cmn.Fmt("Result of tx %d in block %d diverges. Expected %s, got %s", d.TxIndex, d.Height, d.Expected, d.Got)
+}
+
+// VerifyChain re-executes every block in the store against proxyAppConn,
+// which must be connected to a fresh instance of the app.
+// After each block, the app hash is checked against the AppHash of the next
+// header, or of the state for the last block, and each DeliverTx result is
+// checked against the one in the state's last ABCIResponses or TxIndexer, if any.
+// Only the Code and Data of a result are checked, since the Log is not part of consensus.
+// It returns the first divergence, or nil if the app reproduced the chain.
+func VerifyChain(genDoc *types.GenesisDoc, state *State, store types.BlockStoreRPC,
+	proxyAppConn proxy.AppConnConsensus, logger log.Logger) (*ChainDivergence, error) {
+
+	genState := MakeGenesisState(dbm.NewMemDB(), genDoc)
+	if err := proxyAppConn.InitChainSync(types.TM2PB.Validators(genState.Validators)); err != nil {
+		return nil, errors.New(cmn.Fmt("Error calling InitChain: %v", err))
+	}
+
+	lastResponses := state.LoadABCIResponses()
+	storeHeight := store.Height()
+	for height := 1; height <= storeHeight; height++ {
+		block := store.LoadBlock(height)
+		abciResponses, appHash, err := execCommitBlock(proxyAppConn, block, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, tx := range block.Txs {
+			var expected *abci.ResponseDeliverTx
+			if lastResponses.Height == height {
+				expected = lastResponses.DeliverTx[i]
+			} else {
+				expected = indexedTxResult(state, height, i, tx)
+			}
+			if expected == nil {
+				continue
+			}
+			if got := abciResponses.DeliverTx[i]; expected.Code != got.Code || !bytes.Equal(expected.Data, got.Data) {
+				return &ChainDivergence{height, i, deliverTxString(expected), deliverTxString(got)}, nil
+			}
+		}
+
+		var expectedAppHash []byte
+		if height < storeHeight {
+			expectedAppHash = store.LoadBlockMeta(height + 1).Header.AppHash
+		} else if height == state.LastBlockHeight {
+			expectedAppHash = state.AppHash
+		} else {
+			// the store is ahead of the state, so there is nothing to compare the last block to
+			logger.Info("No app hash to verify the last block against", "height", height)
+			break
+		}
+		if !bytes.Equal(expectedAppHash, appHash) {
+			return &ChainDivergence{height, -1, cmn.Fmt("%X", expectedAppHash), cmn.Fmt("%X", appHash)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// indexedTxResult returns the result the TxIndexer has for the tx at
+// the given height and index, or nil if it has none.
+func indexedTxResult(state *State, height, index int, tx types.Tx) *abci.ResponseDeliverTx {
+	txResult, err := state.TxIndexer.Get(tx.Hash())
+	if err != nil || txResult == nil {
+		return nil
+	}
+	// the same tx may be in more than one block
+	if txResult.Height != uint64(height) || txResult.Index != uint32(index) {
+		return nil
+	}
+	return &txResult.Result
+}
+
+func deliverTxString(res *abci.ResponseDeliverTx) string {
+	return cmn.Fmt("{Code:%v Data:%X Log:%q}", res.Code, res.Data, res.Log)
+}
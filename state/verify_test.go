@@ -0,0 +1,96 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/abci/example/dummy"
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/tendermint/proxy"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+	"github.com/tendermint/tmlibs/log"
+)
+
+func TestVerifyChain(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	logger := log.TestingLogger()
+
+	genDoc := &types.GenesisDoc{
+		ChainID: chainID,
+		Validators: []types.GenesisValidator{
+			types.GenesisValidator{privKey.PubKey(), 10000, "test"},
+		},
+	}
+	state := MakeGenesisState(dbm.NewMemDB(), genDoc)
+	state.SetLogger(logger)
+
+	// make a chain of blocks carrying the app hashes of the dummy app
+	store := &testBlockStore{}
+	proxyApp := startProxyApp(t, dummy.NewDummyApplication())
+	var abciResponses *ABCIResponses
+	for height := 1; height <= 3; height++ {
+		block, _ := types.MakeBlock(height, chainID, makeTxs(height), new(types.Commit),
+			types.BlockID{}, state.Validators.Hash(), state.AppHash, testPartSize)
+		var err error
+		abciResponses, state.AppHash, err = execCommitBlock(proxyApp.Consensus(), block, logger)
+		require.Nil(err)
+		store.blocks = append(store.blocks, block)
+		state.LastBlockHeight = height
+	}
+	proxyApp.Stop()
+	state.SaveABCIResponses(abciResponses)
+
+	// the same app reproduces the chain
+	proxyApp = startProxyApp(t, dummy.NewDummyApplication())
+	div, err := VerifyChain(genDoc, state, store, proxyApp.Consensus(), logger)
+	proxyApp.Stop()
+	require.Nil(err)
+	assert.Nil(div)
+
+	// an app with a different app hash diverges after the first block
+	proxyApp = startProxyApp(t, abci.NewBaseApplication())
+	div, err = VerifyChain(genDoc, state, store, proxyApp.Consensus(), logger)
+	proxyApp.Stop()
+	require.Nil(err)
+	if assert.NotNil(div) {
+		assert.Equal(1, div.Height)
+		assert.Equal(-1, div.TxIndex)
+	}
+
+	// a tx result that differs from the recorded one
+	abciResponses.DeliverTx[4] = &abci.ResponseDeliverTx{Code: abci.CodeType_InternalError}
+	state.SaveABCIResponses(abciResponses)
+	proxyApp = startProxyApp(t, dummy.NewDummyApplication())
+	div, err = VerifyChain(genDoc, state, store, proxyApp.Consensus(), logger)
+	proxyApp.Stop()
+	require.Nil(err)
+	if assert.NotNil(div) {
+		assert.Equal(3, div.Height)
+		assert.Equal(4, div.TxIndex)
+	}
+}
+
+func startProxyApp(t *testing.T, app abci.Application) proxy.AppConns {
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(app), nil)
+	_, err := proxyApp.Start()
+	require.Nil(t, err)
+	return proxyApp
+}
+
+// testBlockStore is a BlockStoreRPC holding just the blocks.
+type testBlockStore struct {
+	blocks []*types.Block
+}
+
+func (bs *testBlockStore) Height() int                       { return len(bs.blocks) }
+func (bs *testBlockStore) LoadBlock(height int) *types.Block { return bs.blocks[height-1] }
+func (bs *testBlockStore) LoadBlockMeta(height int) *types.BlockMeta {
+	block := bs.blocks[height-1]
+	return types.NewBlockMeta(block, block.MakePartSet(testPartSize))
+}
+func (bs *testBlockStore) LoadBlockPart(height int, index int) *types.Part { return nil }
+func (bs *testBlockStore) LoadBlockHeightByHash(hash []byte) int           { return 0 }
+func (bs *testBlockStore) LoadBlockCommit(height int) *types.Commit        { return nil }
+func (bs *testBlockStore) LoadSeenCommit(height int) *types.Commit         { return nil }
@@ -0,0 +1,8 @@
+#! /bin/bash
+set -eu
+
+ID=$1
+
+# the test nodes use their validator key as their node key
+cd "$GOPATH/src/github.com/tendermint/tendermint"
+jq -r .address "test/p2p/data/mach$ID/core/priv_validator.json" | tr '[:upper:]' '[:lower:]'
@@ -19,10 +19,10 @@ for i in `seq 1 $N`; do
 done
 
 set -e
-# seeds need quotes
-seeds="\"$(test/p2p/ip.sh 1):46656\""
+# seeds need quotes, and the IDs the nodes report
+seeds="\"$(curl -s $(test/p2p/ip.sh 1):46657/status | jq -r .result.node_id)@$(test/p2p/ip.sh 1):46656\""
 for i in `seq 2 $N`; do
-	seeds="$seeds,\"$(test/p2p/ip.sh $i):46656\""
+	seeds="$seeds,\"$(curl -s $(test/p2p/ip.sh $i):46657/status | jq -r .result.node_id)@$(test/p2p/ip.sh $i):46656\""
 done
 echo $seeds
 
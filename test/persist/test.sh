@@ -2,4 +2,6 @@
 
 cd "$GOPATH/src/github.com/tendermint/tendermint"
 
-bash ./test/persist/test_failure_indices.sh
+# crash tendermint at each fail point, restart, and check it recovers.
+# see github.com/tendermint/tendermint/fail
+go test -v -timeout 30m -run TestCrashRecovery ./node
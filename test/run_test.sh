@@ -12,7 +12,7 @@ bash test/test_cover.sh
 # run the app tests using bash
 bash test/app/test.sh
 
-# run the persistence tests using bash
+# run the persistence tests
 bash test/persist/test.sh
 
 if [[ "$BRANCH" == "master" || $(echo "$BRANCH" | grep "release-") != "" ]]; then
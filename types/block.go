@@ -17,6 +17,16 @@ import (
 const (
 	MaxBlockSize         = 22020096 // 21MB TODO make it configurable
 	DefaultBlockPartSize = 65536    // 64kB TODO: put part size in parts header?
+
+	// go-wire encodes ints and lengths as a byte with the number of bytes
+	// that follow, then up to 8 bytes, so an encoded length is at most 9 bytes.
+	maxVarintBytes = 9
+
+	// How much a block grows, on top of the txs, when txs are added to it.
+	// Each term is the most the field can take with txs, so it's an upper bound:
+	maxTxsOverheadBytes = maxVarintBytes + // Header.NumTxs
+		(2 + 20) + // Header.DataHash, a RIPEMD160 hash with its 2 byte length
+		maxVarintBytes // Data.Txs, the number of txs
 )
 
 type Block struct {
@@ -107,6 +117,12 @@ func (b *Block) Hash() data.Bytes {
 	return b.Header.Hash()
 }
 
+// MaxTxsBytes returns how many bytes of encoded txs (see Tx.WireSize)
+// can be added to the block, which has no txs yet, for it to stay within maxBytes.
+func (b *Block) MaxTxsBytes(maxBytes int) int {
+	return maxBytes - len(wire.BinaryBytes(b)) - maxTxsOverheadBytes
+}
+
 func (b *Block) MakePartSet(partSize int) *PartSet {
 	return NewPartSetFromData(wire.BinaryBytes(b), partSize)
 }
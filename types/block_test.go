@@ -0,0 +1,52 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	wire "github.com/tendermint/go-wire"
+)
+
+func TestBlockMaxTxsBytes(t *testing.T) {
+	assert := assert.New(t)
+	const maxBytes = 100000
+	makeBlock := func(txs Txs) *Block {
+		block, _ := MakeBlock(1000000, "test_chain", txs, &Commit{}, BlockID{}, []byte("vals"), []byte("app"), DefaultBlockPartSize)
+		return block
+	}
+
+	maxTxsBytes := makeBlock(nil).MaxTxsBytes(maxBytes)
+	assert.True(maxTxsBytes > 0)
+
+	// fill the block up with as many txs as fit
+	var txs Txs
+	txsBytes := 0
+	for _, tx := range makeTxs(1000, 300) {
+		if txsBytes+tx.WireSize() > maxTxsBytes {
+			break
+		}
+		txs = append(txs, tx)
+		txsBytes += tx.WireSize()
+	}
+	assert.True(len(txs) > 0)
+	assert.True(len(wire.BinaryBytes(makeBlock(txs))) <= maxBytes)
+
+	// a block filled up to exactly maxTxsBytes, with enough txs for
+	// NumTxs and the number of txs to take multi-byte varints, fits too,
+	// and the overhead isn't wildly overestimated
+	txs, txsBytes = nil, 0
+	for maxTxsBytes-txsBytes >= 100+3 {
+		tx := make(Tx, 98) // 100 bytes encoded
+		txs = append(txs, tx)
+		txsBytes += tx.WireSize()
+	}
+	last := make(Tx, maxTxsBytes-txsBytes-2) // under 256 bytes, so 2 bytes of length
+	txs = append(txs, last)
+	txsBytes += last.WireSize()
+	assert.Equal(maxTxsBytes, txsBytes)
+	assert.True(len(txs) > 255)
+
+	blockBytes := len(wire.BinaryBytes(makeBlock(txs)))
+	assert.True(blockBytes <= maxBytes, "block of %d bytes", blockBytes)
+	assert.True(blockBytes > maxBytes-maxTxsOverheadBytes, "block of %d bytes", blockBytes)
+}
@@ -18,6 +18,12 @@ func EventStringDupeout() string { return "Dupeout" }
 func EventStringFork() string    { return "Fork" }
 func EventStringTx(tx Tx) string { return cmn.Fmt("Tx:%X", tx.Hash()) }
 
+// Mempool events
+func EventStringAddTx() string     { return "AddTx" }
+func EventStringRejectTx() string  { return "RejectTx" }
+func EventStringEvictTx() string   { return "EvictTx" }
+func EventStringIncludeTx() string { return "IncludeTx" }
+
 func EventStringNewBlock() string         { return "NewBlock" }
 func EventStringNewBlockHeader() string   { return "NewBlockHeader" }
 func EventStringNewRound() string         { return "NewRound" }
@@ -37,6 +43,10 @@ var (
 	EventDataNameNewBlock       = "new_block"
 	EventDataNameNewBlockHeader = "new_block_header"
 	EventDataNameTx             = "tx"
+	EventDataNameAddTx          = "add_tx"
+	EventDataNameRejectTx       = "reject_tx"
+	EventDataNameEvictTx        = "evict_tx"
+	EventDataNameIncludeTx      = "include_tx"
 	EventDataNameRoundState     = "round_state"
 	EventDataNameVote           = "vote"
 )
@@ -81,6 +91,10 @@ const (
 	EventDataTypeFork           = byte(0x02)
 	EventDataTypeTx             = byte(0x03)
 	EventDataTypeNewBlockHeader = byte(0x04)
+	EventDataTypeEvictTx        = byte(0x05)
+	EventDataTypeAddTx          = byte(0x06)
+	EventDataTypeRejectTx       = byte(0x07)
+	EventDataTypeIncludeTx      = byte(0x08)
 
 	EventDataTypeRoundState = byte(0x11)
 	EventDataTypeVote       = byte(0x12)
@@ -90,6 +104,10 @@ var tmEventDataMapper = data.NewMapper(TMEventData{}).
 	RegisterImplementation(EventDataNewBlock{}, EventDataNameNewBlock, EventDataTypeNewBlock).
 	RegisterImplementation(EventDataNewBlockHeader{}, EventDataNameNewBlockHeader, EventDataTypeNewBlockHeader).
 	RegisterImplementation(EventDataTx{}, EventDataNameTx, EventDataTypeTx).
+	RegisterImplementation(EventDataAddTx{}, EventDataNameAddTx, EventDataTypeAddTx).
+	RegisterImplementation(EventDataRejectTx{}, EventDataNameRejectTx, EventDataTypeRejectTx).
+	RegisterImplementation(EventDataEvictTx{}, EventDataNameEvictTx, EventDataTypeEvictTx).
+	RegisterImplementation(EventDataIncludeTx{}, EventDataNameIncludeTx, EventDataTypeIncludeTx).
 	RegisterImplementation(EventDataRoundState{}, EventDataNameRoundState, EventDataTypeRoundState).
 	RegisterImplementation(EventDataVote{}, EventDataNameVote, EventDataTypeVote)
 
@@ -115,6 +133,31 @@ type EventDataTx struct {
 	Error  string        `json:"error"` // this is redundant information for now
 }
 
+// Fired when the mempool adds a tx that passed CheckTx
+type EventDataAddTx struct {
+	Tx Tx `json:"tx"`
+}
+
+// Fired when the mempool does not add a tx, because it failed CheckTx
+// or did not fit in the mempool
+type EventDataRejectTx struct {
+	Tx   Tx            `json:"tx"`
+	Code abci.CodeType `json:"code"`
+	Log  string        `json:"log"`
+}
+
+// Fired when the mempool drops a tx that has not been committed
+type EventDataEvictTx struct {
+	Tx     Tx     `json:"tx"`
+	Reason string `json:"reason"`
+}
+
+// Fired when a tx in the mempool is committed in a block
+type EventDataIncludeTx struct {
+	Tx     Tx  `json:"tx"`
+	Height int `json:"height"`
+}
+
 // NOTE: This goes into the replay WAL
 type EventDataRoundState struct {
 	Height int    `json:"height"`
@@ -132,6 +175,10 @@ type EventDataVote struct {
 func (_ EventDataNewBlock) AssertIsTMEventData()       {}
 func (_ EventDataNewBlockHeader) AssertIsTMEventData() {}
 func (_ EventDataTx) AssertIsTMEventData()             {}
+func (_ EventDataAddTx) AssertIsTMEventData()          {}
+func (_ EventDataRejectTx) AssertIsTMEventData()       {}
+func (_ EventDataEvictTx) AssertIsTMEventData()        {}
+func (_ EventDataIncludeTx) AssertIsTMEventData()      {}
 func (_ EventDataRoundState) AssertIsTMEventData()     {}
 func (_ EventDataVote) AssertIsTMEventData()           {}
 
@@ -195,6 +242,24 @@ func FireEventTx(fireable events.Fireable, tx EventDataTx) {
 	fireEvent(fireable, EventStringTx(tx.Tx), TMEventData{tx})
 }
 
+//--- mempool events
+
+func FireEventAddTx(fireable events.Fireable, add EventDataAddTx) {
+	fireEvent(fireable, EventStringAddTx(), TMEventData{add})
+}
+
+func FireEventRejectTx(fireable events.Fireable, reject EventDataRejectTx) {
+	fireEvent(fireable, EventStringRejectTx(), TMEventData{reject})
+}
+
+func FireEventEvictTx(fireable events.Fireable, evict EventDataEvictTx) {
+	fireEvent(fireable, EventStringEvictTx(), TMEventData{evict})
+}
+
+func FireEventIncludeTx(fireable events.Fireable, include EventDataIncludeTx) {
+	fireEvent(fireable, EventStringIncludeTx(), TMEventData{include})
+}
+
 //--- EventDataRoundState events
 
 func FireEventNewRoundStep(fireable events.Fireable, rs EventDataRoundState) {
@@ -3,4 +3,6 @@ package types
 var (
 	PeerStateKey     = "ConsensusReactor.peerState"
 	PeerMempoolChKey = "MempoolReactor.peerMempoolCh"
+
+	PeerMempoolStateKey = "MempoolReactor.peerState"
 )
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	abci "github.com/tendermint/abci/types"
 )
 
@@ -19,22 +21,51 @@ type Mempool interface {
 	Unlock()
 
 	Size() int
+	TxsBytes() int64
 	CheckTx(Tx, func(*abci.Response)) error
 	Reap(int) Txs
-	Update(height int, txs Txs)
+	ReapMaxBytesMaxGas(maxBytes, maxGas int64) Txs
+	TxsPage(start, limit int) Txs
+	Update(height int, txs Txs, commitLog string)
 	Flush()
+
+	TxInfo(hash []byte) *MempoolTxInfo
+	RemoveTx(hash []byte) bool
+}
+
+// MempoolTxInfo is a pending tx and what the mempool knows about it.
+type MempoolTxInfo struct {
+	Tx        Tx          `json:"tx"`
+	Height    int         `json:"height"` // the height the tx was last validated at
+	Time      time.Time   `json:"time"`   // when the tx arrived
+	CheckTx   abci.Result `json:"check_tx"`
+	Priority  int64       `json:"priority"`
+	GasWanted int64       `json:"gas_wanted"`
+}
+
+// MempoolPeerStats counts the txs a peer sent the mempool reactor.
+type MempoolPeerStats struct {
+	Txs       int64 `json:"txs"`
+	Bytes     int64 `json:"bytes"`
+	Invalid   int64 `json:"invalid"`   // txs that failed CheckTx
+	Throttled int64 `json:"throttled"` // txs dropped for being over the peer's rate limits
 }
 
 type MockMempool struct {
 }
 
-func (m MockMempool) Lock()                                        {}
-func (m MockMempool) Unlock()                                      {}
-func (m MockMempool) Size() int                                    { return 0 }
-func (m MockMempool) CheckTx(tx Tx, cb func(*abci.Response)) error { return nil }
-func (m MockMempool) Reap(n int) Txs                               { return Txs{} }
-func (m MockMempool) Update(height int, txs Txs)                   {}
-func (m MockMempool) Flush()                                       {}
+func (m MockMempool) Lock()                                         {}
+func (m MockMempool) Unlock()                                       {}
+func (m MockMempool) Size() int                                     { return 0 }
+func (m MockMempool) TxsBytes() int64                               { return 0 }
+func (m MockMempool) CheckTx(tx Tx, cb func(*abci.Response)) error  { return nil }
+func (m MockMempool) Reap(n int) Txs                                { return Txs{} }
+func (m MockMempool) ReapMaxBytesMaxGas(maxBytes, maxGas int64) Txs { return Txs{} }
+func (m MockMempool) TxsPage(start, limit int) Txs                  { return Txs{} }
+func (m MockMempool) Update(height int, txs Txs, commitLog string)  {}
+func (m MockMempool) Flush()                                        {}
+func (m MockMempool) TxInfo(hash []byte) *MempoolTxInfo             { return nil }
+func (m MockMempool) RemoveTx(hash []byte) bool                     { return false }
 
 //------------------------------------------------------
 // blockstore
@@ -45,6 +76,7 @@ type BlockStoreRPC interface {
 	LoadBlockMeta(height int) *BlockMeta
 	LoadBlock(height int) *Block
 	LoadBlockPart(height int, index int) *Part
+	LoadBlockHeightByHash(hash []byte) int
 
 	LoadBlockCommit(height int) *Commit
 	LoadSeenCommit(height int) *Commit
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	abci "github.com/tendermint/abci/types"
+	wire "github.com/tendermint/go-wire"
 	"github.com/tendermint/go-wire/data"
 	"github.com/tendermint/tmlibs/merkle"
 )
@@ -20,6 +21,11 @@ func (tx Tx) Hash() []byte {
 	return merkle.SimpleHashFromBinary(tx)
 }
 
+// WireSize returns the size of the go-wire encoded tx, as it is in a block.
+func (tx Tx) WireSize() int {
+	return len(wire.BinaryBytes(tx))
+}
+
 func (tx Tx) String() string {
 	return fmt.Sprintf("Tx{%X}", []byte(tx))
 }